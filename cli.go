@@ -0,0 +1,296 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a node in the CLI tree. Setup registers the command's flags
+// on fs and returns the function that runs it with the remaining
+// positional arguments. Completion scripts call Setup on a throwaway
+// FlagSet to discover flags without running anything.
+type command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Setup   func(fs *flag.FlagSet) func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	// Assigned in init because the completion command walks this slice.
+	commands = []command{
+		{
+			Name:    "analyze",
+			Usage:   "analyze [flags]",
+			Summary: "Analyze a codebase and report statistics per language (default command)",
+			Setup:   setupAnalyze,
+		},
+		{
+			Name:    "languages",
+			Usage:   "languages [flags]",
+			Summary: "List supported languages, their extensions and which metrics they support",
+			Setup:   setupLanguages,
+		},
+		{
+			Name:    "explain",
+			Usage:   "explain [flags] <file>",
+			Summary: "Show how each line of a file is classified",
+			Setup:   setupExplain,
+		},
+		{
+			Name:    "diff",
+			Usage:   "diff [flags] <a> <b>",
+			Summary: "Compare two directories, git revisions or saved JSON reports per language and file",
+			Setup:   setupDiff,
+		},
+		{
+			Name:    "history",
+			Usage:   "history [flags]",
+			Summary: "Sample a branch's history and emit a time series of line counts",
+			Setup:   setupHistory,
+		},
+		{
+			Name:    "serve",
+			Usage:   "serve [flags]",
+			Summary: "Serve analysis results as JSON over HTTP",
+			Setup:   setupServe,
+		},
+		{
+			Name:    "config",
+			Usage:   "config print [flags]",
+			Summary: "Print the effective configuration and where each value came from",
+			Setup:   setupConfig,
+		},
+		{
+			Name:    "completion",
+			Usage:   "completion <bash|zsh|fish>",
+			Summary: "Print a shell completion script",
+			Setup:   setupCompletion,
+		},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCLI dispatches to a subcommand. Anything that isn't a known command
+// name is treated as flags for analyze, so the original flat invocation
+// (walker -path src -top 5) keeps working.
+func runCLI(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			if len(args) > 1 {
+				if cmd, ok := findCommand(args[1]); ok {
+					printCommandUsage(os.Stdout, cmd)
+					return nil
+				}
+			}
+			printUsage()
+			return nil
+		}
+		if cmd, ok := findCommand(args[0]); ok {
+			return runCommand(cmd, args[1:])
+		}
+	}
+
+	cmd, _ := findCommand("analyze")
+	return runCommand(cmd, args)
+}
+
+func runCommand(cmd command, args []string) error {
+	fs := newCommandFlagSet(cmd)
+	run := cmd.Setup(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return run(fs.Args())
+}
+
+func newCommandFlagSet(cmd command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: walker %s\n\n%s\n", cmd.Usage, cmd.Summary)
+		if hasFlags(fs) {
+			fmt.Fprintf(out, "\nFlags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// printCommandUsage writes cmd's usage, including the flags its Setup
+// registers, to w.
+func printCommandUsage(w io.Writer, cmd command) {
+	fs := newCommandFlagSet(cmd)
+	cmd.Setup(fs)
+	fs.SetOutput(w)
+	fs.Usage()
+}
+
+func hasFlags(fs *flag.FlagSet) bool {
+	found := false
+	fs.VisitAll(func(*flag.Flag) { found = true })
+	return found
+}
+
+func printUsage() {
+	fmt.Println("Usage: walker [command] [flags]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-12s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Println()
+	fmt.Println("Run 'walker help <command>' for details on a command.")
+	fmt.Println("Flags given without a command are passed to analyze.")
+}
+
+func setupAnalyze(fs *flag.FlagSet) func(args []string) error {
+	config := bindAnalyzeFlags(fs)
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		cfg, err := config()
+		if err != nil {
+			return err
+		}
+		return runAnalyze(cfg)
+	}
+}
+
+func setupLanguages(fs *flag.FlagSet) func(args []string) error {
+	format := fs.String("format", "table", "Output format (table, json)")
+	loadDefs := bindLanguageDefsFlag(fs)
+	return func(args []string) error {
+		if err := loadDefs(); err != nil {
+			return err
+		}
+		return outputLanguages(os.Stdout, *format)
+	}
+}
+
+func setupCompletion(fs *flag.FlagSet) func(args []string) error {
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected one shell name: bash, zsh or fish")
+		}
+		script, err := completionScript(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	}
+}
+
+// commandFlags returns the sorted flag names a command accepts.
+func commandFlags(cmd command) []string {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	cmd.Setup(fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+	})
+	sort.Strings(names)
+	return names
+}
+
+func completionScript(shell string) (string, error) {
+	var b strings.Builder
+	var names []string
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+
+	switch shell {
+	case "bash":
+		b.WriteString("# bash completion for walker\n")
+		b.WriteString("_walker() {\n")
+		b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+		b.WriteString("    local cmd=\"${COMP_WORDS[1]}\"\n")
+		b.WriteString("    local flags\n")
+		b.WriteString("    case \"$cmd\" in\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, "        %s) flags=\"%s\" ;;\n", cmd.Name, dashed(commandFlags(cmd)))
+		}
+		analyze, _ := findCommand("analyze")
+		fmt.Fprintf(&b, "        *) flags=\"%s\" ;;\n", dashed(commandFlags(analyze)))
+		b.WriteString("    esac\n")
+		b.WriteString("    if [[ $COMP_CWORD -eq 1 && \"$cur\" != -* ]]; then\n")
+		fmt.Fprintf(&b, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+		b.WriteString("    elif [[ \"$cur\" == -* ]]; then\n")
+		b.WriteString("        COMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+		b.WriteString("    else\n")
+		b.WriteString("        COMPREPLY=($(compgen -f -- \"$cur\"))\n")
+		b.WriteString("    fi\n")
+		b.WriteString("}\n")
+		b.WriteString("complete -F _walker walker\n")
+	case "zsh":
+		b.WriteString("#compdef walker\n\n")
+		b.WriteString("_walker() {\n")
+		b.WriteString("    local -a commands\n")
+		b.WriteString("    commands=(\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, "        '%s:%s'\n", cmd.Name, zshEscape(cmd.Summary))
+		}
+		b.WriteString("    )\n")
+		b.WriteString("    if (( CURRENT == 2 )) && [[ $words[2] != -* ]]; then\n")
+		b.WriteString("        _describe 'command' commands\n")
+		b.WriteString("        return\n")
+		b.WriteString("    fi\n")
+		b.WriteString("    case $words[2] in\n")
+		for _, cmd := range commands {
+			fmt.Fprintf(&b, "        %s) compadd -- %s ;;\n", cmd.Name, dashed(commandFlags(cmd)))
+		}
+		analyze, _ := findCommand("analyze")
+		fmt.Fprintf(&b, "        *) compadd -- %s ;;\n", dashed(commandFlags(analyze)))
+		b.WriteString("    esac\n")
+		b.WriteString("    _files\n")
+		b.WriteString("}\n\n")
+		b.WriteString("compdef _walker walker\n")
+	case "fish":
+		b.WriteString("# fish completion for walker\n")
+		fmt.Fprintf(&b, "complete -c walker -f -n '__fish_use_subcommand' -a '%s'\n", strings.Join(names, " "))
+		for _, cmd := range commands {
+			for _, name := range commandFlags(cmd) {
+				fmt.Fprintf(&b, "complete -c walker -n '__fish_seen_subcommand_from %s' -o %s\n", cmd.Name, name)
+			}
+		}
+		analyze, _ := findCommand("analyze")
+		for _, name := range commandFlags(analyze) {
+			fmt.Fprintf(&b, "complete -c walker -n '__fish_use_subcommand' -o %s\n", name)
+		}
+	default:
+		return "", fmt.Errorf("unsupported shell %q (want bash, zsh or fish)", shell)
+	}
+
+	return b.String(), nil
+}
+
+func dashed(names []string) string {
+	out := make([]string, len(names))
+	for i, name := range names {
+		out[i] = "-" + name
+	}
+	return strings.Join(out, " ")
+}
+
+func zshEscape(s string) string {
+	s = strings.ReplaceAll(s, "'", "'\\''")
+	return strings.ReplaceAll(s, ":", "\\:")
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandUsage(t *testing.T) {
+	cmd, ok := findCommand("analyze")
+	if !ok {
+		t.Fatal("analyze is not registered")
+	}
+	var b strings.Builder
+	printCommandUsage(&b, cmd)
+	out := b.String()
+	if !strings.Contains(out, "Usage: walker analyze [flags]") {
+		t.Errorf("usage is missing the command line:\n%s", out)
+	}
+	if !strings.Contains(out, "-path") {
+		t.Errorf("usage does not list -path:\n%s", out)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/XanaOG/Walker/walker"
+	"gopkg.in/yaml.v3"
+)
+
+var projectConfigNames = []string{".walker.yaml", ".walker.yml"}
+
+// fileConfig mirrors the analyze flags. Pointers distinguish "not set"
+// from zero values so a file can turn a default off.
+type fileConfig struct {
+	Format             *string               `yaml:"format"`
+	Progress           *bool                 `yaml:"progress"`
+	Top                *int                  `yaml:"top"`
+	TopComplex         *int                  `yaml:"top_complex"`
+	Complexity         *bool                 `yaml:"complexity"`
+	TopLong            *int                  `yaml:"top_long"`
+	TopNested          *int                  `yaml:"top_nested"`
+	Dup                *int                  `yaml:"dup"`
+	DupTokens          *int                  `yaml:"dup_tokens"`
+	DupIgnoreIDs       *bool                 `yaml:"dup_ignore_ids"`
+	Dedupe             *bool                 `yaml:"dedupe"`
+	ExcludeGenerated   *bool                 `yaml:"exclude_generated"`
+	GeneratedPatterns  []string              `yaml:"generated_patterns"`
+	GeneratedMarkers   []string              `yaml:"generated_markers"`
+	TestPatterns       []string              `yaml:"test_patterns"`
+	TestDirs           []string              `yaml:"test_dirs"`
+	ProductionPatterns []string              `yaml:"production_patterns"`
+	Detailed           *bool                 `yaml:"detailed"`
+	ByDirectory        *bool                 `yaml:"by_dir"`
+	Template           *string               `yaml:"template"`
+	Badges             *string               `yaml:"badges"`
+	Chart              *string               `yaml:"chart"`
+	LanguageDefs       *string               `yaml:"lang_defs"`
+	Timeout            *time.Duration        `yaml:"timeout"`
+	NestedArchives     *bool                 `yaml:"nested_archives"`
+	Exclude            []string              `yaml:"exclude"`
+	Include            []string              `yaml:"include"`
+	Rules              *fileRules            `yaml:"rules"`
+	Profiles           map[string]fileConfig `yaml:"profiles"`
+}
+
+// fileRules is the rules section. Per-language comment ratios and
+// forbidden languages can only be set here.
+type fileRules struct {
+	MaxFileLines           *int                 `yaml:"max_file_lines"`
+	MaxFunctions           *int                 `yaml:"max_functions"`
+	MaxFunctionLines       *int                 `yaml:"max_function_lines"`
+	MaxComplexity          *int                 `yaml:"max_complexity"`
+	MinCommentRatio        *float64             `yaml:"min_comment_ratio"`
+	CommentRatioByLanguage map[string]float64   `yaml:"comment_ratio_by_language"`
+	Baseline               *string              `yaml:"baseline"`
+	MaxGrowth              *float64             `yaml:"max_growth"`
+	Forbidden              []forbiddenLanguages `yaml:"forbidden"`
+}
+
+type forbiddenLanguages struct {
+	Path      string   `yaml:"path"`
+	Languages []string `yaml:"languages"`
+}
+
+// configSources records where each effective setting came from, keyed by
+// flag name.
+type configSources map[string]string
+
+type configLayer struct {
+	source string
+	// dir is the directory of the config file, which relative paths in
+	// it are resolved against.
+	dir    string
+	values fileConfig
+}
+
+func loadFileConfig(path string) (fileConfig, error) {
+	var fc fileConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fc, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return fc, fmt.Errorf("%s: %w", path, err)
+	}
+	return fc, nil
+}
+
+// globalConfigPath returns $XDG_CONFIG_HOME/walker/config.yaml, falling
+// back to ~/.config when XDG_CONFIG_HOME is unset.
+func globalConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "walker", "config.yaml")
+}
+
+// findProjectConfig looks for a project config in root and each of its
+// parents, returning the nearest one.
+func findProjectConfig(root string) string {
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return ""
+	}
+	for {
+		for _, name := range projectConfigNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadConfigLayers returns the global and project configs, each followed
+// by its profile section when profile is set, in increasing precedence.
+func loadConfigLayers(root, explicit, profile string) ([]configLayer, error) {
+	var paths []string
+	if global := globalConfigPath(); global != "" {
+		if _, err := os.Stat(global); err == nil {
+			paths = append(paths, global)
+		}
+	}
+	if explicit != "" {
+		paths = append(paths, explicit)
+	} else if project := findProjectConfig(root); project != "" {
+		paths = append(paths, project)
+	}
+
+	var layers []configLayer
+	profileFound := false
+	for _, path := range paths {
+		fc, err := loadFileConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		dir := filepath.Dir(path)
+		layers = append(layers, configLayer{path, dir, fc})
+
+		if profile == "" {
+			continue
+		}
+		if p, ok := fc.Profiles[profile]; ok {
+			profileFound = true
+			layers = append(layers, configLayer{fmt.Sprintf("%s (profile %s)", path, profile), dir, p})
+		}
+	}
+
+	if profile != "" && !profileFound {
+		return nil, fmt.Errorf("profile %q not found in any config file", profile)
+	}
+	return layers, nil
+}
+
+// applyConfigLayers copies file values into config for every flag that
+// wasn't given on the command line. Flags always win over files. Relative
+// file and directory paths are taken relative to the config file they
+// appear in, so a config found in a parent directory still points at the
+// same files.
+func applyConfigLayers(config *Config, layers []configLayer, setFlags map[string]bool, sources configSources) {
+	for _, layer := range layers {
+		v := layer.values
+		set := func(name string, present bool, apply func()) {
+			if !present || setFlags[name] {
+				return
+			}
+			apply()
+			sources[name] = layer.source
+		}
+		setPath := func(name string, value *string, apply func(string)) {
+			if value == nil || setFlags[name] {
+				return
+			}
+			path, source := *value, layer.source
+			if path != "" && !filepath.IsAbs(path) {
+				path = filepath.Join(layer.dir, path)
+				source = fmt.Sprintf("%s (%s relative to its directory)", layer.source, *value)
+			}
+			apply(path)
+			sources[name] = source
+		}
+
+		set("format", v.Format != nil, func() { config.OutputFormat = *v.Format })
+		set("progress", v.Progress != nil, func() { config.ShowProgress = *v.Progress })
+		set("top", v.Top != nil, func() { config.TopFiles = *v.Top })
+		set("top-complex", v.TopComplex != nil, func() { config.TopComplex = *v.TopComplex })
+		set("complexity", v.Complexity != nil, func() { config.Complexity = *v.Complexity })
+		set("top-long", v.TopLong != nil, func() { config.TopLong = *v.TopLong })
+		set("top-nested", v.TopNested != nil, func() { config.TopNested = *v.TopNested })
+		set("dup", v.Dup != nil, func() { config.Dup = *v.Dup })
+		set("dup-tokens", v.DupTokens != nil, func() { config.DupTokens = *v.DupTokens })
+		set("dup-ignore-ids", v.DupIgnoreIDs != nil, func() { config.DupIgnoreIDs = *v.DupIgnoreIDs })
+		set("dedupe", v.Dedupe != nil, func() { config.Dedupe = *v.Dedupe })
+		set("exclude-generated", v.ExcludeGenerated != nil, func() { config.ExcludeGenerated = *v.ExcludeGenerated })
+		set("generated", v.GeneratedPatterns != nil, func() { config.GeneratedPatterns = v.GeneratedPatterns })
+		set("generated_markers", v.GeneratedMarkers != nil, func() { config.GeneratedMarkers = v.GeneratedMarkers })
+		set("tests", v.TestPatterns != nil, func() { config.TestPatterns = v.TestPatterns })
+		set("test_dirs", v.TestDirs != nil, func() { config.TestDirs = v.TestDirs })
+		set("production_patterns", v.ProductionPatterns != nil, func() { config.ProductionPatterns = v.ProductionPatterns })
+		set("detailed", v.Detailed != nil, func() { config.Detailed = *v.Detailed })
+		set("by-dir", v.ByDirectory != nil, func() { config.ByDirectory = *v.ByDirectory })
+		setPath("template", v.Template, func(path string) { config.Template = path })
+		setPath("badges", v.Badges, func(path string) { config.BadgeDir = path })
+		setPath("chart", v.Chart, func(path string) { config.ChartFile = path })
+		setPath("lang-defs", v.LanguageDefs, func(path string) { config.LanguageDefs = path })
+		set("timeout", v.Timeout != nil, func() { config.Timeout = *v.Timeout })
+		set("nested-archives", v.NestedArchives != nil, func() { config.Nested = *v.NestedArchives })
+		set("exclude", v.Exclude != nil, func() { config.Exclude = v.Exclude })
+		set("include", v.Include != nil, func() { config.Include = v.Include })
+
+		if r := v.Rules; r != nil {
+			rules := &config.Rules
+			set("max-file-lines", r.MaxFileLines != nil, func() { rules.MaxFileLines = *r.MaxFileLines })
+			set("max-functions", r.MaxFunctions != nil, func() { rules.MaxFunctions = *r.MaxFunctions })
+			set("max-function-lines", r.MaxFunctionLines != nil, func() { rules.MaxFunctionLines = *r.MaxFunctionLines })
+			set("max-complexity", r.MaxComplexity != nil, func() { rules.MaxComplexity = *r.MaxComplexity })
+			set("min-comment-ratio", r.MinCommentRatio != nil, func() { rules.MinCommentRatio = *r.MinCommentRatio })
+			set("comment_ratio_by_language", r.CommentRatioByLanguage != nil, func() { rules.CommentRatioByLanguage = r.CommentRatioByLanguage })
+			setPath("baseline", r.Baseline, func(path string) { rules.Baseline = path })
+			set("max-growth", r.MaxGrowth != nil, func() { rules.MaxGrowth = *r.MaxGrowth })
+			set("forbidden", r.Forbidden != nil, func() {
+				rules.Forbidden = nil
+				for _, f := range r.Forbidden {
+					rules.Forbidden = append(rules.Forbidden, walker.ForbiddenLanguages{Path: f.Path, Languages: f.Languages})
+				}
+			})
+		}
+	}
+}
+
+func setupConfig(fs *flag.FlagSet) func(args []string) error {
+	resolve := bindConfigFlags(fs)
+	return func(args []string) error {
+		if len(args) == 0 || args[0] != "print" {
+			return fmt.Errorf("expected 'print'")
+		}
+		// Flags may also follow the action: walker config print -profile ci
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+		}
+		config, sources, err := resolve()
+		if err != nil {
+			return err
+		}
+		printEffectiveConfig(os.Stdout, config, sources)
+		return nil
+	}
+}
+
+func printEffectiveConfig(w io.Writer, config Config, sources configSources) {
+	values := map[string]string{
+		"path":                      config.Root,
+		"format":                    config.OutputFormat,
+		"progress":                  fmt.Sprint(config.ShowProgress),
+		"top":                       fmt.Sprint(config.TopFiles),
+		"top-complex":               fmt.Sprint(config.TopComplex),
+		"complexity":                fmt.Sprint(config.Complexity),
+		"top-long":                  fmt.Sprint(config.TopLong),
+		"top-nested":                fmt.Sprint(config.TopNested),
+		"dup":                       fmt.Sprint(config.Dup),
+		"dup-tokens":                fmt.Sprint(config.DupTokens),
+		"dup-ignore-ids":            fmt.Sprint(config.DupIgnoreIDs),
+		"dedupe":                    fmt.Sprint(config.Dedupe),
+		"exclude-generated":         fmt.Sprint(config.ExcludeGenerated),
+		"generated":                 strings.Join(config.GeneratedPatterns, ","),
+		"generated_markers":         strings.Join(config.GeneratedMarkers, ","),
+		"tests":                     strings.Join(config.TestPatterns, ","),
+		"test_dirs":                 strings.Join(config.TestDirs, ","),
+		"production_patterns":       strings.Join(config.ProductionPatterns, ","),
+		"detailed":                  fmt.Sprint(config.Detailed),
+		"by-dir":                    fmt.Sprint(config.ByDirectory),
+		"template":                  config.Template,
+		"badges":                    config.BadgeDir,
+		"chart":                     config.ChartFile,
+		"lang-defs":                 config.LanguageDefs,
+		"timeout":                   config.Timeout.String(),
+		"nested-archives":           fmt.Sprint(config.Nested),
+		"rev":                       config.Rev,
+		"max-file-lines":            fmt.Sprint(config.Rules.MaxFileLines),
+		"max-functions":             fmt.Sprint(config.Rules.MaxFunctions),
+		"max-function-lines":        fmt.Sprint(config.Rules.MaxFunctionLines),
+		"max-complexity":            fmt.Sprint(config.Rules.MaxComplexity),
+		"min-comment-ratio":         fmt.Sprint(config.Rules.MinCommentRatio),
+		"baseline":                  config.Rules.Baseline,
+		"max-growth":                fmt.Sprint(config.Rules.MaxGrowth),
+		"comment_ratio_by_language": fmt.Sprint(len(config.Rules.CommentRatioByLanguage)) + " languages",
+		"forbidden":                 fmt.Sprint(len(config.Rules.Forbidden)) + " rules",
+		"exclude":                   strings.Join(config.Exclude, ","),
+		"include":                   strings.Join(config.Include, ","),
+	}
+
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "%-25s %-40s %s\n", "SETTING", "VALUE", "SOURCE")
+	fmt.Fprintln(w, strings.Repeat("─", 105))
+	for _, key := range keys {
+		source := sources[key]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(w, "%-25s %-40s %s\n", key, walker.TruncateString(values[key], 40), source)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigPathsFromSubdirectory(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "src", "pkg")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `template: tmpl/report.tmpl
+badges: out/badges
+chart: /tmp/chart.svg
+lang_defs: langs.yaml
+rules:
+  baseline: stats/main.json
+`
+	if err := os.WriteFile(filepath.Join(dir, ".walker.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	layers, err := loadConfigLayers(sub, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Config
+	sources := make(configSources)
+	applyConfigLayers(&got, layers, map[string]bool{"badges": true}, sources)
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"template", got.Template, filepath.Join(dir, "tmpl", "report.tmpl")},
+		{"badges", got.BadgeDir, ""},
+		{"chart", got.ChartFile, "/tmp/chart.svg"},
+		{"lang-defs", got.LanguageDefs, filepath.Join(dir, "langs.yaml")},
+		{"baseline", got.Rules.Baseline, filepath.Join(dir, "stats", "main.json")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+	if source := sources["template"]; !strings.Contains(source, "tmpl/report.tmpl relative to") {
+		t.Errorf("template source = %q, want it to say the path was relative", source)
+	}
+	if source := sources["chart"]; source != filepath.Join(dir, ".walker.yaml") {
+		t.Errorf("chart source = %q, want the config file", source)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/XanaOG/Walker/walker"
+)
+
+func setupDiff(fs *flag.FlagSet) func(args []string) error {
+	repo := fs.String("path", ".", "Directory inside the git repository, for arguments that are revisions")
+	format := fs.String("format", "table", "Output format (table, json)")
+	top := fs.Int("top", 20, "Show the top N changed files")
+	applyFilters := bindFilterFlags(fs)
+	loadDefs := bindLanguageDefsFlag(fs)
+	return func(args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("expected two directories, revisions or JSON reports to compare")
+		}
+		if *format != "table" && *format != "json" {
+			return fmt.Errorf("unsupported format %q (want table or json)", *format)
+		}
+		if err := loadDefs(); err != nil {
+			return err
+		}
+
+		base := Config{Root: *repo}
+		applyFilters(&base)
+
+		ctx, cancel := interruptContext(0)
+		defer cancel()
+
+		var reports [2]*walker.Report
+		for i, arg := range args {
+			report, err := loadDiffSide(ctx, arg, base)
+			if err != nil {
+				return fmt.Errorf("%s: %w", arg, err)
+			}
+			reports[i] = report
+		}
+
+		diff := walker.DiffReports(reports[0], reports[1])
+		if *format == "json" {
+			return walker.RenderDiffJSON(os.Stdout, diff)
+		}
+		walker.RenderDiff(os.Stdout, diff, walker.DiffOptions{TopFiles: *top})
+		return nil
+	}
+}
+
+// loadDiffSide turns one diff argument into a report. A .json file is a
+// report saved with -format json, any other existing path is a directory
+// or archive to analyze, and anything else is a git revision of the
+// repository at base.Root.
+func loadDiffSide(ctx context.Context, arg string, base Config) (*walker.Report, error) {
+	info, err := os.Stat(arg)
+	switch {
+	case err == nil && !info.IsDir() && strings.HasSuffix(strings.ToLower(arg), ".json"):
+		file, err := os.Open(arg)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		return walker.ReadJSON(file)
+	case err == nil:
+		base.Root = arg
+	default:
+		base.Rev = arg
+	}
+	return walker.Analyze(ctx, base.options())
+}
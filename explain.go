@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/XanaOG/Walker/walker"
+	"github.com/fatih/color"
+)
+
+type explainOptions struct {
+	Language string
+	Only     string
+}
+
+func setupExplain(fs *flag.FlagSet) func(args []string) error {
+	var opts explainOptions
+	fs.StringVar(&opts.Language, "lang", "", "Explain the file as this language instead of detecting it")
+	fs.StringVar(&opts.Only, "only", "", "Only print lines of this kind (code, comment, doc, blank)")
+	loadDefs := bindLanguageDefsFlag(fs)
+	return func(args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("expected exactly one file to explain")
+		}
+		if err := loadDefs(); err != nil {
+			return err
+		}
+		return explainFile(os.Stdout, args[0], opts)
+	}
+}
+
+// explainFile prints every line of path prefixed with the classification
+// the language's patterns give it, the comment pattern responsible, and
+// whether the function or class pattern fired. The totals printed at the
+// end come from the language's analyzer, as in the analyze command; when
+// that analyzer disagrees with the patterns (GoAnalyzer counts functions
+// with go/parser, for one) a note says so.
+func explainFile(w io.Writer, path string, opts explainOptions) error {
+	lang := opts.Language
+	if lang == "" {
+		var ok bool
+		lang, ok = registry.Detect(path)
+		if !ok {
+			return fmt.Errorf("%s: no language matches this file (use -lang)", path)
+		}
+	}
+	langConfig, ok := registry.Lookup(lang)
+	if !ok {
+		return fmt.Errorf("unknown language %q", lang)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s (%s)\n\n", path, lang)
+
+	scanned := walker.FileStats{Path: path}
+	err = walker.ScanLines(bytes.NewReader(src), langConfig, func(line walker.Line) {
+		scanned.AddLine(line)
+		if opts.Only != "" && opts.Only != line.Kind.String() {
+			return
+		}
+
+		matched := ""
+		if line.CommentPattern != nil {
+			matched = line.CommentPattern.String()
+		}
+		fmt.Fprintf(w, "%5d %s %s %-22s | %s\n",
+			line.Number,
+			kindLabel(line.Kind),
+			patternMarks(line),
+			walker.TruncateString(matched, 22),
+			line.Text)
+	})
+	if err != nil {
+		return err
+	}
+	scanned.Finish()
+
+	stats, err := registry.AnalyzerFor(lang).Analyze(bytes.NewReader(src), path, langConfig)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%s\n", explainTotals(stats))
+	if patterns := explainTotals(scanned); patterns != explainTotals(stats) {
+		fmt.Fprintf(w, "note: the %s analyzer's totals differ from the lines above, which count %s\n", lang, patterns)
+	}
+	return nil
+}
+
+func explainTotals(stats walker.FileStats) string {
+	return fmt.Sprintf("%d lines: %d code, %d comment, %d blank, %d functions, %d classes",
+		stats.Lines, stats.CodeLines, stats.CommentLines, stats.BlankLines, stats.Functions, stats.Classes)
+}
+
+func kindLabel(kind walker.LineKind) string {
+	label := fmt.Sprintf("%-7s", kind)
+	switch kind {
+	case walker.LineComment, walker.LineDoc:
+		return color.GreenString(label)
+	case walker.LineBlank:
+		return color.New(color.FgHiBlack).Sprint(label)
+	default:
+		return color.CyanString(label)
+	}
+}
+
+// patternMarks shows "F" when FunctionPattern matched and "C" when
+// ClassPattern did.
+func patternMarks(line walker.Line) string {
+	marks := []byte("--")
+	if line.Function {
+		marks[0] = 'F'
+	}
+	if line.Class {
+		marks[1] = 'C'
+	}
+	return color.YellowString(string(marks))
+}
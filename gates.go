@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/XanaOG/Walker/walker"
+)
+
+// exitGatesFailed is the exit status when analysis succeeded but a quality
+// gate did not, so CI can tell a failing rule from a broken run.
+const exitGatesFailed = 3
+
+// ruleConfig holds the quality gate settings from flags and config files.
+type ruleConfig struct {
+	MaxFileLines           int
+	MaxFunctions           int
+	MaxFunctionLines       int
+	MaxComplexity          int
+	MinCommentRatio        float64
+	CommentRatioByLanguage map[string]float64
+	Baseline               string
+	MaxGrowth              float64
+	Forbidden              []walker.ForbiddenLanguages
+}
+
+// gatesFailed is returned by runAnalyze when any rule was violated.
+type gatesFailed struct {
+	violations int
+}
+
+func (e *gatesFailed) Error() string {
+	return fmt.Sprintf("quality gates failed: %d violations", e.violations)
+}
+
+func bindRuleFlags(fs *flag.FlagSet, rules *ruleConfig) {
+	fs.IntVar(&rules.MaxFileLines, "max-file-lines", 0, "Fail when any file has more lines than this")
+	fs.IntVar(&rules.MaxFunctions, "max-functions", 0, "Fail when any file declares more functions than this")
+	fs.IntVar(&rules.MaxFunctionLines, "max-function-lines", 0, "Fail when any function has more lines than this")
+	fs.IntVar(&rules.MaxComplexity, "max-complexity", 0, "Fail when any function's cyclomatic complexity is above this")
+	fs.Float64Var(&rules.MinCommentRatio, "min-comment-ratio", 0, "Fail when a language's comment ratio is below this percentage")
+	fs.StringVar(&rules.Baseline, "baseline", "", "JSON report to compare against for -max-growth")
+	fs.Float64Var(&rules.MaxGrowth, "max-growth", 0, "Fail when code lines grew by more than this percentage over -baseline")
+}
+
+// rules converts the settings into walker.Rules, loading the baseline
+// report if one is configured.
+func (rc ruleConfig) rules() (walker.Rules, error) {
+	rules := walker.Rules{
+		MaxFileLines:           rc.MaxFileLines,
+		MaxFunctions:           rc.MaxFunctions,
+		MaxFunctionLines:       rc.MaxFunctionLines,
+		MaxComplexity:          rc.MaxComplexity,
+		MinCommentRatio:        rc.MinCommentRatio,
+		CommentRatioByLanguage: rc.CommentRatioByLanguage,
+		MaxGrowth:              rc.MaxGrowth,
+		Forbidden:              rc.Forbidden,
+		Registry:               registry,
+	}
+	if rc.MaxGrowth > 0 && rc.Baseline == "" {
+		return rules, fmt.Errorf("-max-growth needs a -baseline report")
+	}
+	if rc.Baseline == "" {
+		return rules, nil
+	}
+
+	file, err := os.Open(rc.Baseline)
+	if err != nil {
+		return rules, fmt.Errorf("loading baseline: %w", err)
+	}
+	defer file.Close()
+	if rules.Baseline, err = walker.ReadJSON(file); err != nil {
+		return rules, fmt.Errorf("loading baseline %s: %w", rc.Baseline, err)
+	}
+	return rules, nil
+}
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/fatih/color v1.16.0
 	github.com/schollz/progressbar/v3 v3.14.1
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
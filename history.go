@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XanaOG/Walker/walker"
+	"github.com/schollz/progressbar/v3"
+)
+
+func setupHistory(fs *flag.FlagSet) func(args []string) error {
+	cfg := Config{}
+	fs.StringVar(&cfg.Root, "path", ".", "Directory inside the git repository to analyze")
+	fs.StringVar(&cfg.Rev, "rev", "HEAD", "Branch whose first-parent history is walked")
+	since := fs.String("since", "", "First sample date (YYYY-MM-DD or RFC 3339); defaults to the first commit")
+	until := fs.String("until", "", "Last sample date (YYYY-MM-DD or RFC 3339); defaults to now")
+	every := fs.String("every", "1w", "Sampling interval (e.g. 1d, 2w, 12h)")
+	format := fs.String("format", "csv", "Output format (csv, json)")
+	chart := fs.String("chart", "", "Write an SVG line chart of code lines per language to this file")
+	showProgress := fs.Bool("progress", true, "Show progress bar on stderr")
+	applyFilters := bindFilterFlags(fs)
+	loadDefs := bindLanguageDefsFlag(fs)
+	return func(args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("unexpected arguments: %s", strings.Join(args, " "))
+		}
+		if *format != "csv" && *format != "json" {
+			return fmt.Errorf("unsupported format %q (want csv or json)", *format)
+		}
+		if err := loadDefs(); err != nil {
+			return err
+		}
+		applyFilters(&cfg)
+
+		opts := walker.HistoryOptions{Analysis: cfg.options()}
+		var err error
+		if opts.Every, err = parseInterval(*every); err != nil {
+			return fmt.Errorf("-every: %w", err)
+		}
+		if opts.Since, err = parseDate(*since); err != nil {
+			return fmt.Errorf("-since: %w", err)
+		}
+		if opts.Until, err = parseDate(*until); err != nil {
+			return fmt.Errorf("-until: %w", err)
+		}
+
+		var bar *progressbar.ProgressBar
+		if *showProgress {
+			opts.Progress = func(done, total int) {
+				if bar == nil {
+					bar = newProgressBar(total,
+						progressbar.OptionSetDescription("Analyzing snapshots..."),
+						progressbar.OptionSetWriter(os.Stderr))
+				}
+				bar.Set(done)
+			}
+		}
+
+		ctx, cancel := interruptContext(0)
+		defer cancel()
+
+		points, err := walker.History(ctx, opts)
+		if bar != nil {
+			bar.Exit()
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		// On Ctrl-C, still emit the points finished so far.
+		if *format == "json" {
+			if renderErr := walker.RenderHistoryJSON(os.Stdout, points); renderErr != nil {
+				return renderErr
+			}
+		} else if renderErr := walker.RenderHistoryCSV(os.Stdout, points); renderErr != nil {
+			return renderErr
+		}
+		if err != nil {
+			return fmt.Errorf("history interrupted; results are partial")
+		}
+
+		if *chart != "" {
+			if err := os.WriteFile(*chart, []byte(walker.RenderHistoryChart(points)), 0644); err != nil {
+				return fmt.Errorf("writing chart: %w", err)
+			}
+		}
+		return nil
+	}
+}
+
+// parseInterval accepts Go durations plus whole days (1d) and weeks (2w).
+func parseInterval(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil || count <= 0 {
+				return 0, fmt.Errorf("invalid interval %q", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q", s)
+	}
+	return d, nil
+}
+
+// parseDate accepts YYYY-MM-DD, taken as local midnight, or RFC 3339. An
+// empty string is the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import "flag"
+
+// bindLanguageDefsFlag registers -lang-defs on commands that don't take
+// the full analyze flag set. The returned function loads the file, if
+// one was given, once fs has been parsed.
+func bindLanguageDefsFlag(fs *flag.FlagSet) func() error {
+	path := fs.String("lang-defs", "", "YAML or JSON file of extra or overridden language definitions")
+	return func() error {
+		if *path == "" {
+			return nil
+		}
+		return registry.LoadDefinitionsFile(*path)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/XanaOG/Walker/walker"
+	"github.com/fatih/color"
+)
+
+type languageInfo struct {
+	Name       string   `json:"name"`
+	Extensions []string `json:"extensions"`
+	Filenames  []string `json:"filenames,omitempty"`
+	Functions  bool     `json:"functions"`
+	Classes    bool     `json:"classes"`
+	Comments   bool     `json:"comments"`
+}
+
+func listLanguages() []languageInfo {
+	var infos []languageInfo
+	for _, name := range registry.Names() {
+		langConfig, _ := registry.Lookup(name)
+		infos = append(infos, languageInfo{
+			Name:       name,
+			Extensions: langConfig.Extensions,
+			Filenames:  langConfig.Filenames,
+			Functions:  langConfig.FunctionPattern != nil,
+			Classes:    langConfig.ClassPattern != nil,
+			Comments:   len(langConfig.CommentPatterns) > 0,
+		})
+	}
+	return infos
+}
+
+func outputLanguages(w io.Writer, format string) error {
+	infos := listLanguages()
+
+	switch format {
+	case "json":
+		jsonData, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(jsonData))
+	case "table":
+		fmt.Fprintf(w, "%-12s %-40s %6s %8s %9s\n", "LANGUAGE", "EXTENSIONS", "FUNCS", "CLASSES", "COMMENTS")
+		fmt.Fprintln(w, strings.Repeat("─", 79))
+		for _, info := range infos {
+			// Copy first: appending to Extensions could write into the
+			// registry's own slice.
+			names := append(append([]string(nil), info.Extensions...), info.Filenames...)
+			fmt.Fprintf(w, "%-12s %-40s %s %s %s\n",
+				info.Name,
+				walker.TruncateString(strings.Join(names, " "), 40),
+				supportMark(info.Functions, 6),
+				supportMark(info.Classes, 8),
+				supportMark(info.Comments, 9))
+		}
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+	return nil
+}
+
+// supportMark pads before colouring so escape codes don't skew the columns.
+func supportMark(supported bool, width int) string {
+	if supported {
+		return color.GreenString("%*s", width, "yes")
+	}
+	return color.New(color.FgHiBlack).Sprintf("%*s", width, "no")
+}
@@ -1,47 +1,23 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
+	"os/signal"
 	"regexp"
-	"sort"
 	"strings"
-	"sync"
 	"time"
 
+	"github.com/XanaOG/Walker/walker"
 	"github.com/fatih/color"
 	"github.com/schollz/progressbar/v3"
 )
 
-type FileStats struct {
-	Path         string
-	Lines        int
-	CodeLines    int
-	CommentLines int
-	BlankLines   int
-	Characters   int
-	Functions    int
-	Classes      int
-	Size         int64
-}
-
-type LanguageStats struct {
-	Files        int
-	Lines        int
-	CodeLines    int
-	CommentLines int
-	BlankLines   int
-	Characters   int
-	Functions    int
-	Classes      int
-	Size         int64
-	FileStats    []FileStats
-}
-
+// Config holds the analyze command's settings as given by flags and
+// config files. runAnalyze translates it into walker.Options.
 type Config struct {
 	Root         string
 	OutputFormat string
@@ -49,776 +25,350 @@ type Config struct {
 	Exclude      []string
 	Include      []string
 	TopFiles     int
+	TopComplex   int
+	Complexity   bool
+	TopLong      int
+	TopNested    int
+	Dup          int
+	DupTokens    int
+	DupIgnoreIDs bool
+	Dedupe       bool
 	Detailed     bool
 	ByDirectory  bool
+	Template     string
+	BadgeDir     string
+	ChartFile    string
+	LanguageDefs string
+	Timeout      time.Duration
+	Nested       bool
+	Rev          string
+	Rules        ruleConfig
+
+	// GeneratedPatterns and GeneratedMarkers extend the default rules for
+	// generated files; Generated holds the result once flags are parsed.
+	GeneratedPatterns []string
+	GeneratedMarkers  []string
+	Generated         *walker.GeneratedRules
+	ExcludeGenerated  bool
+
+	// TestPatterns, TestDirs and ProductionPatterns extend the default
+	// rules for test code; Tests holds the result once flags are parsed.
+	TestPatterns       []string
+	TestDirs           []string
+	ProductionPatterns []string
+	Tests              *walker.TestRules
 }
 
-type LanguageConfig struct {
-	Extensions       []string
-	FunctionPattern  *regexp.Regexp
-	ClassPattern     *regexp.Regexp
-	CommentPatterns  []*regexp.Regexp
-	StringDelimiters []string
-}
-
-var languages = map[string]LanguageConfig{
-	"Go": {
-		Extensions:      []string{".go"},
-		FunctionPattern: regexp.MustCompile(`^\s*func\s+(\w+|\([^)]*\)\s*\w+)\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*type\s+\w+\s+(struct|interface)`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Python": {
-		Extensions:      []string{".py", ".pyw", ".pyx"},
-		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-			regexp.MustCompile(`^\s*""".*?"""`),
-			regexp.MustCompile(`^\s*'''.*?'''`),
-		},
-	},
-	"JavaScript": {
-		Extensions:      []string{".js", ".jsx", ".mjs", ".cjs"},
-		FunctionPattern: regexp.MustCompile(`^\s*(function\s+\w+|const\s+\w+\s*=\s*\(|let\s+\w+\s*=\s*\(|var\s+\w+\s*=\s*\(|\w+\s*:\s*function|\w+\s*=>\s*)`),
-		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"TypeScript": {
-		Extensions:      []string{".ts", ".tsx"},
-		FunctionPattern: regexp.MustCompile(`^\s*(function\s+\w+|const\s+\w+\s*=\s*\(|let\s+\w+\s*=\s*\(|export\s+function|\w+\s*:\s*\(|\w+\s*=>\s*)`),
-		ClassPattern:    regexp.MustCompile(`^\s*(export\s+)?(abstract\s+)?class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Java": {
-		Extensions:      []string{".java"},
-		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected|static|\s)*\s+\w+\s+\w+\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*(public|private|protected)?\s*(abstract\s+)?(class|interface)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"C": {
-		Extensions:      []string{".c", ".h"},
-		FunctionPattern: regexp.MustCompile(`^\s*\w+\s+\w+\s*\(`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"C++": {
-		Extensions:      []string{".cpp", ".cc", ".cxx", ".hpp", ".hxx"},
-		FunctionPattern: regexp.MustCompile(`^\s*(\w+\s+)*\w+\s+\w+\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*(class|struct)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"C#": {
-		Extensions:      []string{".cs"},
-		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected|internal|static|\s)*\s+\w+\s+\w+\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*(public|private|protected|internal)?\s*(abstract\s+)?(class|interface|struct)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Rust": {
-		Extensions:      []string{".rs"},
-		FunctionPattern: regexp.MustCompile(`^\s*(pub\s+)?fn\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*(pub\s+)?(struct|enum|trait)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"PHP": {
-		Extensions:      []string{".php", ".phtml"},
-		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected)?\s*function\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*(abstract\s+)?(class|interface|trait)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`^\s*#`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Ruby": {
-		Extensions:      []string{".rb", ".rbw"},
-		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Swift": {
-		Extensions:      []string{".swift"},
-		FunctionPattern: regexp.MustCompile(`^\s*(private|public|internal)?\s*func\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*(public|private|internal)?\s*(class|struct|protocol)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Kotlin": {
-		Extensions:      []string{".kt", ".kts"},
-		FunctionPattern: regexp.MustCompile(`^\s*(private|public|internal|protected)?\s*fun\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*(public|private|internal|protected)?\s*(class|interface|object)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Shell": {
-		Extensions:      []string{".sh", ".bash", ".zsh", ".fish"},
-		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*\(\s*\)\s*\{`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"HTML": {
-		Extensions: []string{".html", ".htm", ".xhtml"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`<!--.*?-->`),
-		},
-	},
-	"CSS": {
-		Extensions: []string{".css", ".scss", ".sass", ".less"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`/\*.*?\*/`),
-			regexp.MustCompile(`^\s*//`), // SCSS/Sass comments
-		},
-	},
-	"SQL": {
-		Extensions: []string{".sql"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*--`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"YAML": {
-		Extensions: []string{".yml", ".yaml"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"JSON": {
-		Extensions: []string{".json"},
-	},
-	"XML": {
-		Extensions: []string{".xml", ".xsd", ".xsl"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`<!--.*?-->`),
-		},
-	},
-	"Markdown": {
-		Extensions: []string{".md", ".markdown"},
-	},
-	"TOML": {
-		Extensions: []string{".toml"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"INI": {
-		Extensions: []string{".ini", ".cfg", ".conf"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*[#;]`),
-		},
-	},
-	"Dart": {
-		Extensions:      []string{".dart"},
-		FunctionPattern: regexp.MustCompile(`^\s*(static\s+)?\w+\s+\w+\s*\(`),
-		ClassPattern:    regexp.MustCompile(`^\s*(abstract\s+)?class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Scala": {
-		Extensions:      []string{".scala", ".sc"},
-		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*(class|object|trait)\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"Lua": {
-		Extensions:      []string{".lua"},
-		FunctionPattern: regexp.MustCompile(`^\s*(local\s+)?function\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*--`),
-		},
-	},
-	"Perl": {
-		Extensions:      []string{".pl", ".pm", ".perl"},
-		FunctionPattern: regexp.MustCompile(`^\s*sub\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"R": {
-		Extensions:      []string{".r", ".R", ".Rmd"},
-		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*<-\s*function`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"MATLAB": {
-		Extensions:      []string{".m", ".mlx"},
-		FunctionPattern: regexp.MustCompile(`^\s*function\s+.*=\s*\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*%`),
-		},
-	},
-	"Julia": {
-		Extensions:      []string{".jl"},
-		FunctionPattern: regexp.MustCompile(`^\s*function\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Haskell": {
-		Extensions:      []string{".hs", ".lhs"},
-		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*::`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*--`),
-		},
-	},
-	"Erlang": {
-		Extensions:      []string{".erl", ".hrl"},
-		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*\(`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*%`),
-		},
-	},
-	"Elixir": {
-		Extensions:      []string{".ex", ".exs"},
-		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"F#": {
-		Extensions:      []string{".fs", ".fsx", ".fsi"},
-		FunctionPattern: regexp.MustCompile(`^\s*let\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`\(\*.*?\*\)`),
-		},
-	},
-	"OCaml": {
-		Extensions:      []string{".ml", ".mli"},
-		FunctionPattern: regexp.MustCompile(`^\s*let\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`\(\*.*?\*\)`),
-		},
-	},
-	"Assembly": {
-		Extensions: []string{".asm", ".s", ".S"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*[#;]`),
-		},
-	},
-	"Vim": {
-		Extensions:      []string{".vim", ".vimrc"},
-		FunctionPattern: regexp.MustCompile(`^\s*function!?\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*"`),
-		},
-	},
-	"Batch": {
-		Extensions:      []string{".bat", ".cmd"},
-		FunctionPattern: regexp.MustCompile(`^\s*:\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*rem\s`),
-			regexp.MustCompile(`^\s*::`),
-		},
-	},
-	"PowerShell": {
-		Extensions:      []string{".ps1", ".psm1", ".psd1"},
-		FunctionPattern: regexp.MustCompile(`^\s*function\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Dockerfile": {
-		Extensions: []string{"Dockerfile", ".dockerfile"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Terraform": {
-		Extensions: []string{".tf", ".tfvars"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-	"GraphQL": {
-		Extensions: []string{".graphql", ".gql"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Protobuf": {
-		Extensions: []string{".proto"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-		},
-	},
-	"CMake": {
-		Extensions:      []string{".cmake", "CMakeLists.txt"},
-		FunctionPattern: regexp.MustCompile(`^\s*function\s*\(`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Makefile": {
-		Extensions: []string{"Makefile", ".mk", "makefile"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*#`),
-		},
-	},
-	"Properties": {
-		Extensions: []string{".properties", ".env"},
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*[#!]`),
-		},
-	},
-	"Groovy": {
-		Extensions:      []string{".groovy", ".gradle"},
-		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
-		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
-		CommentPatterns: []*regexp.Regexp{
-			regexp.MustCompile(`^\s*//`),
-			regexp.MustCompile(`/\*.*?\*/`),
-		},
-	},
-}
-
-var (
-	defaultExcludes = []string{
-		".git", ".svn", ".hg", ".bzr",
-		"node_modules", "vendor", "target", "build", "dist",
-		".idea", ".vscode", ".vs", "*.exe", "*.dll", "*.so", "*.dylib",
-		"*.jar", "*.war", "*.class", "*.pyc", "*.pyo", "__pycache__",
-		".DS_Store", "Thumbs.db",
-	}
-)
+// registry is shared by every command so -lang-defs applies wherever
+// languages are looked up.
+var registry = walker.NewRegistry()
 
 func main() {
-	config := parseFlags()
-
-	if config.ShowProgress {
-		fmt.Println(color.CyanString("Walker - Code Analysis Tool"))
-		fmt.Printf("%s\n", color.New(color.FgHiBlack).Sprintf("Analyzing codebase at: %s", config.Root))
-		fmt.Println()
-	}
-
-	stats, err := analyzeCodebase(config)
-	if err != nil {
-		fmt.Printf("Error analyzing codebase: %v\n", err)
+	if err := runCLI(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		var gates *gatesFailed
+		if errors.As(err, &gates) {
+			os.Exit(exitGatesFailed)
+		}
 		os.Exit(1)
 	}
-
-	switch config.OutputFormat {
-	case "json":
-		outputJSON(stats)
-	case "table":
-		fallthrough
-	default:
-		outputTable(stats, config)
-	}
 }
 
-func parseFlags() Config {
-	var config Config
-
-	flag.StringVar(&config.Root, "path", ".", "Root directory to analyze")
-	flag.StringVar(&config.OutputFormat, "format", "table", "Output format (table, json)")
-	flag.BoolVar(&config.ShowProgress, "progress", true, "Show progress bar")
-	flag.IntVar(&config.TopFiles, "top", 10, "Show top N files by lines")
-	flag.BoolVar(&config.Detailed, "detailed", false, "Show detailed file statistics")
-	flag.BoolVar(&config.ByDirectory, "by-dir", false, "Group results by directory")
-
-	var excludeStr, includeStr string
-	flag.StringVar(&excludeStr, "exclude", "", "Comma-separated list of patterns to exclude")
-	flag.StringVar(&includeStr, "include", "", "Comma-separated list of patterns to include")
-
-	flag.Parse()
-
-	if excludeStr != "" {
-		config.Exclude = strings.Split(excludeStr, ",")
-	}
-	config.Exclude = append(config.Exclude, defaultExcludes...)
-
-	if includeStr != "" {
-		config.Include = strings.Split(includeStr, ",")
+// interruptContext is cancelled by the first Ctrl-C so the current
+// analysis can wind down and print what it has. A second Ctrl-C kills the
+// process as usual.
+func interruptContext(timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	if timeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, func() {
+		cancel()
+		stop()
 	}
-
-	return config
 }
 
-func analyzeCodebase(config Config) (map[string]*LanguageStats, error) {
-	stats := make(map[string]*LanguageStats)
-	var mu sync.Mutex
+func (config Config) options() walker.Options {
+	opts := walker.Options{
+		Root:           config.Root,
+		Exclude:        config.Exclude,
+		Include:        config.Include,
+		Registry:       registry,
+		NestedArchives: config.Nested,
+		Rev:            config.Rev,
+		Dedupe:         config.Dedupe,
+
+		Generated:        config.Generated,
+		ExcludeGenerated: config.ExcludeGenerated,
+		Tests:            config.Tests,
+
+		// Only work out complexity and Halstead measures for the outputs
+		// that show them; they cost several times the line counts.
+		Complexity: config.Complexity || config.TopComplex > 0 || config.Rules.MaxComplexity > 0 ||
+			config.Template != "" || config.OutputFormat == "json" || config.OutputFormat == "ndjson",
+	}
+	if config.Dup > 0 {
+		opts.Clones = &walker.CloneOptions{MinTokens: config.DupTokens, IgnoreIdentifiers: config.DupIgnoreIDs}
+	}
+	return opts
+}
 
-	extToLang := make(map[string]string)
-	for lang, langConfig := range languages {
-		for _, ext := range langConfig.Extensions {
-			extToLang[ext] = lang
+func runAnalyze(config Config) error {
+	if config.LanguageDefs != "" {
+		if err := registry.LoadDefinitionsFile(config.LanguageDefs); err != nil {
+			return fmt.Errorf("loading language definitions: %w", err)
 		}
 	}
 
-	fileChan := make(chan string, 1000)
-	var wg sync.WaitGroup
-
-	var totalFiles int
-	filepath.Walk(config.Root, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
-		}
-		if shouldProcessFile(path, config) {
-			totalFiles++
-		}
-		return nil
-	})
-
-	var bar *progressbar.ProgressBar
-	if config.ShowProgress && totalFiles > 0 {
-		bar = progressbar.NewOptions(totalFiles,
-			progressbar.OptionSetDescription("Analyzing files..."),
-			progressbar.OptionSetTheme(progressbar.Theme{
-				Saucer:        "█",
-				SaucerHead:    "█",
-				SaucerPadding: "░",
-				BarStart:      "[",
-				BarEnd:        "]",
-			}),
-			progressbar.OptionShowCount(),
-			progressbar.OptionShowIts(),
-			progressbar.OptionSetWidth(50),
-		)
+	rules, err := config.Rules.rules()
+	if err != nil {
+		return err
 	}
 
-	worker := func() {
-		defer wg.Done()
-		for path := range fileChan {
-			ext := strings.ToLower(filepath.Ext(path))
-			if lang, ok := extToLang[ext]; ok {
-				fileStats := analyzeFile(path, languages[lang])
-
-				mu.Lock()
-				if stats[lang] == nil {
-					stats[lang] = &LanguageStats{FileStats: make([]FileStats, 0)}
-				}
-				langStats := stats[lang]
-				langStats.Files++
-				langStats.Lines += fileStats.Lines
-				langStats.CodeLines += fileStats.CodeLines
-				langStats.CommentLines += fileStats.CommentLines
-				langStats.BlankLines += fileStats.BlankLines
-				langStats.Characters += fileStats.Characters
-				langStats.Functions += fileStats.Functions
-				langStats.Classes += fileStats.Classes
-				langStats.Size += fileStats.Size
-				langStats.FileStats = append(langStats.FileStats, fileStats)
-				mu.Unlock()
-			}
+	opts := config.options()
 
-			if bar != nil {
-				bar.Add(1)
-			}
-		}
+	var stream *walker.NDJSONWriter
+	if config.OutputFormat == "ndjson" {
+		// Records go to stdout as they complete, so the banner and
+		// progress bar would only get in the way.
+		config.ShowProgress = false
+		stream = walker.NewNDJSONWriter(os.Stdout)
+		opts.OnFile = stream.WriteFile
 	}
 
-	numWorkers := 20
-	wg.Add(numWorkers)
-	for i := 0; i < numWorkers; i++ {
-		go worker()
+	if config.OutputFormat == "sarif" {
+		// SARIF is usually redirected straight into an upload step.
+		config.ShowProgress = false
 	}
 
-	err := filepath.Walk(config.Root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.IsDir() || !shouldProcessFile(path, config) {
-			return nil
+	var bar *progressbar.ProgressBar
+	if config.ShowProgress {
+		fmt.Println(color.CyanString("Walker - Code Analysis Tool"))
+		target := config.Root
+		if config.Rev != "" {
+			target = fmt.Sprintf("%s (revision %s)", config.Root, config.Rev)
 		}
-		fileChan <- path
-		return nil
-	})
-
-	close(fileChan)
-	wg.Wait()
-
-	if bar != nil {
-		bar.Finish()
+		fmt.Printf("%s\n", color.New(color.FgHiBlack).Sprintf("Analyzing codebase at: %s", target))
 		fmt.Println()
-	}
-
-	return stats, err
-}
-
-func shouldProcessFile(path string, config Config) bool {
-	for _, pattern := range config.Exclude {
-		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-			return false
-		}
-		if strings.Contains(path, pattern) {
-			return false
-		}
-	}
 
-	if len(config.Include) > 0 {
-		for _, pattern := range config.Include {
-			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
-				return true
+		opts.Progress = func(done, total int) {
+			if total == 0 || done == 0 {
+				return
 			}
-		}
-		return false
-	}
-
-	ext := strings.ToLower(filepath.Ext(path))
-	for _, langConfig := range languages {
-		for _, supportedExt := range langConfig.Extensions {
-			if ext == supportedExt {
-				return true
+			if bar == nil {
+				bar = newProgressBar(total)
 			}
+			bar.Set(done)
 		}
 	}
 
-	return false
-}
-
-func analyzeFile(path string, langConfig LanguageConfig) FileStats {
-	file, err := os.Open(path)
-	if err != nil {
-		return FileStats{Path: path}
-	}
-	defer file.Close()
+	ctx, cancel := interruptContext(config.Timeout)
+	defer cancel()
 
-	info, _ := file.Stat()
-	stats := FileStats{
-		Path: path,
-		Size: info.Size(),
-	}
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		stats.Lines++
-		stats.Characters += len(line) + 1
-
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" {
-			stats.BlankLines++
-		} else if isCommentLine(trimmed, langConfig.CommentPatterns) {
-			stats.CommentLines++
+	report, err := walker.Analyze(ctx, opts)
+	if bar != nil {
+		if report != nil && report.Incomplete {
+			bar.Exit()
 		} else {
-			stats.CodeLines++
-			if langConfig.FunctionPattern != nil && langConfig.FunctionPattern.MatchString(line) {
-				stats.Functions++
-			}
-			if langConfig.ClassPattern != nil && langConfig.ClassPattern.MatchString(line) {
-				stats.Classes++
-			}
+			bar.Finish()
 		}
+		fmt.Println()
 	}
-
-	return stats
-}
-
-func isCommentLine(line string, patterns []*regexp.Regexp) bool {
-	for _, pattern := range patterns {
-		if pattern.MatchString(line) {
-			return true
-		}
+	if err != nil && (report == nil || !report.Incomplete) {
+		return fmt.Errorf("analyzing codebase: %w", err)
 	}
-	return false
-}
-
-func outputTable(stats map[string]*LanguageStats, config Config) {
-	if len(stats) == 0 {
-		color.Yellow("No supported code files found!")
-		return
+	// Gates on a partial report would pass or fail for the wrong reasons.
+	if rules.Enabled() && !report.Incomplete {
+		report.QualityGates = walker.CheckRules(report, rules)
 	}
-
-	color.Cyan("\nCode Analysis Results")
-	fmt.Printf("%s\n", color.New(color.FgHiBlack).Sprintf("Generated on: %s", time.Now().Format("2006-01-02 15:04:05")))
-	fmt.Println()
-
-	type langSort struct {
-		name  string
-		stats *LanguageStats
+	if err := outputReport(report, config, stream); err != nil {
+		return err
 	}
-	var sorted []langSort
-	for lang, langStats := range stats {
-		sorted = append(sorted, langSort{lang, langStats})
+	if report.Incomplete {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("analysis timed out after %s; results are partial", config.Timeout)
+		}
+		return fmt.Errorf("analysis interrupted; results are partial")
 	}
-	sort.Slice(sorted, func(i, j int) bool {
-		return sorted[i].stats.Lines > sorted[j].stats.Lines
-	})
-
-	// Print clean, well-formatted table
-	fmt.Printf("%-15s %8s %12s %12s %12s %8s %12s %8s %10s\n",
-		"LANGUAGE", "FILES", "LINES", "CODE", "COMMENTS", "BLANK", "CHARS", "FUNCS", "CLASSES")
-
-	fmt.Println(strings.Repeat("─", 120))
-
-	var totals LanguageStats
-	for _, item := range sorted {
-		lang, langStats := item.name, item.stats
-
-		fmt.Printf("%-15s %8d %12d %12d %12d %8d %12d %8d %10d\n",
-			lang,
-			langStats.Files,
-			langStats.Lines,
-			langStats.CodeLines,
-			langStats.CommentLines,
-			langStats.BlankLines,
-			langStats.Characters,
-			langStats.Functions,
-			langStats.Classes)
-
-		totals.Files += langStats.Files
-		totals.Lines += langStats.Lines
-		totals.CodeLines += langStats.CodeLines
-		totals.CommentLines += langStats.CommentLines
-		totals.BlankLines += langStats.BlankLines
-		totals.Characters += langStats.Characters
-		totals.Functions += langStats.Functions
-		totals.Classes += langStats.Classes
-		totals.Size += langStats.Size
+	if gates := report.QualityGates; gates != nil && !gates.Passed() {
+		return &gatesFailed{len(gates.Violations)}
 	}
+	return nil
+}
 
-	fmt.Println(strings.Repeat("─", 120))
-	fmt.Printf("%-15s %8d %12d %12d %12d %8d %12d %8d %10d\n",
-		"TOTAL",
-		totals.Files,
-		totals.Lines,
-		totals.CodeLines,
-		totals.CommentLines,
-		totals.BlankLines,
-		totals.Characters,
-		totals.Functions,
-		totals.Classes)
-
-	if config.TopFiles > 0 {
-		showTopFiles(stats, config.TopFiles)
+func outputReport(report *walker.Report, config Config, stream *walker.NDJSONWriter) error {
+	// Badges and charts tend to be committed by CI, so never write them
+	// from a partial analysis.
+	if config.BadgeDir != "" && !report.Incomplete {
+		if err := writeBadges(config.BadgeDir, report); err != nil {
+			return fmt.Errorf("writing badges: %w", err)
+		}
 	}
 
-	// Show summary
-	fmt.Printf("\n Summary:\n")
-	fmt.Printf("   Total Size: %s\n", formatBytes(totals.Size))
-	fmt.Printf("   Code Ratio: %.1f%%\n", float64(totals.CodeLines)/float64(totals.Lines)*100)
-	if totals.Functions > 0 {
-		fmt.Printf("   Avg Lines/Function: %.1f\n", float64(totals.CodeLines)/float64(totals.Functions))
+	if config.ChartFile != "" && !report.Incomplete {
+		if err := os.WriteFile(config.ChartFile, []byte(walker.RenderChart(report)), 0644); err != nil {
+			return fmt.Errorf("writing chart: %w", err)
+		}
 	}
 
-	fmt.Printf("\n %s\n", color.BlueString("https://github.com/XanaOG/Walker"))
-	fmt.Printf("   %s\n", color.New(color.FgHiBlack).Sprint("Please respect the original author"))
-}
-
-func showTopFiles(stats map[string]*LanguageStats, topN int) {
-	fmt.Printf("\n Top %d Files by Lines:\n", topN)
-
-	var allFiles []FileStats
-	for _, langStats := range stats {
-		allFiles = append(allFiles, langStats.FileStats...)
+	if config.Template != "" {
+		if err := outputTemplate(report, config); err != nil {
+			return fmt.Errorf("rendering template: %w", err)
+		}
+		return nil
 	}
 
-	sort.Slice(allFiles, func(i, j int) bool {
-		return allFiles[i].Lines > allFiles[j].Lines
-	})
-
-	if len(allFiles) > topN {
-		allFiles = allFiles[:topN]
+	switch config.OutputFormat {
+	case "json":
+		return walker.RenderJSON(os.Stdout, report)
+	case "ndjson":
+		stream.WriteSummary(report)
+	case "sarif":
+		return walker.RenderSARIF(os.Stdout, report)
+	case "table":
+		fallthrough
+	default:
+		walker.RenderTable(os.Stdout, report, walker.TableOptions{TopFiles: config.TopFiles, TopComplex: config.TopComplex, TopLong: config.TopLong, TopNested: config.TopNested, TopClones: config.Dup, ByDirectory: config.ByDirectory})
 	}
+	return nil
+}
 
-	for i, file := range allFiles {
-		fmt.Printf("%2d. %-55s %10d lines %12d chars\n",
-			i+1,
-			truncateString(file.Path, 55),
-			file.Lines,
-			file.Characters)
-	}
+// newProgressBar returns the bar shared by every command; extra options
+// override the defaults.
+func newProgressBar(total int, extra ...progressbar.Option) *progressbar.ProgressBar {
+	options := []progressbar.Option{
+		progressbar.OptionSetDescription("Analyzing files..."),
+		progressbar.OptionSetTheme(progressbar.Theme{
+			Saucer:        "█",
+			SaucerHead:    "█",
+			SaucerPadding: "░",
+			BarStart:      "[",
+			BarEnd:        "]",
+		}),
+		progressbar.OptionShowCount(),
+		progressbar.OptionShowIts(),
+		progressbar.OptionSetWidth(50),
+	}
+	return progressbar.NewOptions(total, append(options, extra...)...)
 }
 
-func outputJSON(stats map[string]*LanguageStats) {
-	output := struct {
-		GeneratedAt time.Time                 `json:"generated_at"`
-		Languages   map[string]*LanguageStats `json:"languages"`
-		Summary     map[string]interface{}    `json:"summary"`
-	}{
-		GeneratedAt: time.Now(),
-		Languages:   stats,
+// bindAnalyzeFlags registers the analysis flags on fs. The returned
+// function builds the Config once fs has been parsed.
+func bindAnalyzeFlags(fs *flag.FlagSet) func() (Config, error) {
+	resolve := bindConfigFlags(fs)
+	return func() (Config, error) {
+		config, _, err := resolve()
+		return config, err
 	}
+}
 
-	// Calculate summary
-	var totals LanguageStats
-	for _, langStats := range stats {
-		totals.Files += langStats.Files
-		totals.Lines += langStats.Lines
-		totals.CodeLines += langStats.CodeLines
-		totals.CommentLines += langStats.CommentLines
-		totals.BlankLines += langStats.BlankLines
-		totals.Characters += langStats.Characters
-		totals.Functions += langStats.Functions
-		totals.Classes += langStats.Classes
-		totals.Size += langStats.Size
-	}
+// bindConfigFlags is bindAnalyzeFlags plus a record of where each value
+// came from. Values from config files fill in any flag not given on the
+// command line.
+func bindConfigFlags(fs *flag.FlagSet) func() (Config, configSources, error) {
+	var config Config
 
-	output.Summary = map[string]interface{}{
-		"total_files":      totals.Files,
-		"total_lines":      totals.Lines,
-		"total_code_lines": totals.CodeLines,
-		"total_comments":   totals.CommentLines,
-		"total_blank":      totals.BlankLines,
-		"total_chars":      totals.Characters,
-		"total_functions":  totals.Functions,
-		"total_classes":    totals.Classes,
-		"total_size":       totals.Size,
-		"code_ratio":       float64(totals.CodeLines) / float64(totals.Lines) * 100,
-	}
+	fs.StringVar(&config.Root, "path", ".", "Root directory or archive (.zip, .jar, .tar, .tar.gz) to analyze")
+	fs.StringVar(&config.OutputFormat, "format", "table", "Output format (table, json, ndjson, sarif)")
+	fs.BoolVar(&config.ShowProgress, "progress", true, "Show progress bar")
+	fs.IntVar(&config.TopFiles, "top", 10, "Show top N files by lines")
+	fs.IntVar(&config.TopNested, "top-nested", 0, "Show nesting depth and indentation per language and the top N most deeply nested functions")
+	fs.IntVar(&config.TopLong, "top-long", 0, "Show a histogram of function lengths and the top N longest functions")
+	fs.IntVar(&config.TopComplex, "top-complex", 0, "Show complexity per language and the top N functions by cyclomatic complexity")
+	fs.BoolVar(&config.Complexity, "complexity", false, "Compute complexity, Halstead measures and the maintainability index (implied by -top-complex, -max-complexity, -template and json and ndjson output)")
+	fs.IntVar(&config.Dup, "dup", 0, "Find duplicated code and show the duplication per language and the N largest clone groups")
+	fs.IntVar(&config.DupTokens, "dup-tokens", 50, "Shortest run of tokens -dup reports as a clone")
+	fs.BoolVar(&config.DupIgnoreIDs, "dup-ignore-ids", false, "Let -dup match copies whose identifiers were renamed")
+	fs.BoolVar(&config.Dedupe, "dedupe", false, "Count files with identical content only once")
+	fs.BoolVar(&config.ExcludeGenerated, "exclude-generated", false, "Leave generated files out of the results")
+	generatedStr := fs.String("generated", "", "Comma-separated file name patterns to treat as generated, besides the defaults")
+	testsStr := fs.String("tests", "", "Comma-separated file name patterns to treat as test code, besides the defaults")
+	fs.BoolVar(&config.Detailed, "detailed", false, "Show detailed file statistics")
+	fs.BoolVar(&config.ByDirectory, "by-dir", false, "Roll results up by directory, with test and production code lines")
+	fs.StringVar(&config.Template, "template", "", "Render output with a Go text/template file instead of -format")
+	fs.StringVar(&config.BadgeDir, "badges", "", "Write SVG badges (lines, top language, comment ratio) to this directory")
+	fs.StringVar(&config.ChartFile, "chart", "", "Write an SVG bar chart of code lines per language to this file")
+	fs.DurationVar(&config.Timeout, "timeout", 0, "Stop after this long and report partial results (e.g. 30s, 5m)")
+	fs.StringVar(&config.Rev, "rev", "", "Analyze this git commit, tag or branch from the repository instead of the working tree")
+	fs.BoolVar(&config.Nested, "nested-archives", false, "Analyze the contents of archives found inside the tree")
+	fs.StringVar(&config.LanguageDefs, "lang-defs", "", "YAML or JSON file of extra or overridden language definitions")
+	bindRuleFlags(fs, &config.Rules)
+	configFile := fs.String("config", "", "Config file to use instead of discovering .walker.yaml")
+	profile := fs.String("profile", "", "Apply the named profile from the config files")
+	applyFilters := bindFilterFlags(fs)
+
+	return func() (Config, configSources, error) {
+		setFlags := make(map[string]bool)
+		sources := make(configSources)
+		fs.Visit(func(f *flag.Flag) {
+			setFlags[f.Name] = true
+			sources[f.Name] = "flag -" + f.Name
+		})
+
+		layers, err := loadConfigLayers(config.Root, *configFile, *profile)
+		if err != nil {
+			return config, nil, err
+		}
+		applyConfigLayers(&config, layers, setFlags, sources)
+		applyFilters(&config)
+		if *generatedStr != "" {
+			config.GeneratedPatterns = strings.Split(*generatedStr, ",")
+		}
+		if config.Generated, err = generatedRules(config.GeneratedPatterns, config.GeneratedMarkers); err != nil {
+			return config, nil, err
+		}
+		if *testsStr != "" {
+			config.TestPatterns = strings.Split(*testsStr, ",")
+		}
+		config.Tests = testRules(config.TestPatterns, config.TestDirs, config.ProductionPatterns)
 
-	jsonData, err := json.MarshalIndent(output, "", "  ")
-	if err != nil {
-		fmt.Printf("Error marshaling JSON: %v\n", err)
-		return
+		return config, sources, nil
 	}
-
-	fmt.Println(string(jsonData))
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+// generatedRules adds patterns and markers to walker.DefaultGeneratedRules.
+func generatedRules(patterns, markers []string) (*walker.GeneratedRules, error) {
+	rules := walker.DefaultGeneratedRules()
+	rules.Patterns = append(rules.Patterns, patterns...)
+	for _, marker := range markers {
+		re, err := regexp.Compile(marker)
+		if err != nil {
+			return nil, fmt.Errorf("generated marker %q: %w", marker, err)
+		}
+		rules.Markers = append(rules.Markers, re)
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return &rules, nil
 }
 
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
+// testRules adds patterns and directories to walker.DefaultTestRules.
+func testRules(patterns, dirs, production []string) *walker.TestRules {
+	rules := walker.DefaultTestRules()
+	rules.Patterns = append(rules.Patterns, patterns...)
+	rules.Dirs = append(rules.Dirs, dirs...)
+	rules.Production = append(rules.Production, production...)
+	return &rules
+}
+
+// bindFilterFlags registers -exclude and -include on fs, for commands that
+// walk a tree but don't share the rest of analyze's flags.
+func bindFilterFlags(fs *flag.FlagSet) func(config *Config) {
+	var excludeStr, includeStr string
+	fs.StringVar(&excludeStr, "exclude", "", "Comma-separated list of patterns to exclude")
+	fs.StringVar(&includeStr, "include", "", "Comma-separated list of patterns to include")
+
+	return func(config *Config) {
+		if excludeStr != "" {
+			config.Exclude = strings.Split(excludeStr, ",")
+		}
+		config.Exclude = append(config.Exclude, walker.DefaultExcludes...)
+
+		if includeStr != "" {
+			config.Include = strings.Split(includeStr, ",")
+		}
 	}
-	return s[:maxLen-3] + "..."
 }
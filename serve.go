@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+
+	"github.com/XanaOG/Walker/walker"
+)
+
+func setupServe(fs *flag.FlagSet) func(args []string) error {
+	var cfg Config
+	fs.StringVar(&cfg.Root, "path", ".", "Root directory to analyze")
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	applyFilters := bindFilterFlags(fs)
+	loadDefs := bindLanguageDefsFlag(fs)
+	return func(args []string) error {
+		if err := loadDefs(); err != nil {
+			return err
+		}
+		applyFilters(&cfg)
+
+		http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/" {
+				http.NotFound(w, r)
+				return
+			}
+			// Analyze on every request so the response always reflects
+			// the tree as it is now.
+			opts := cfg.options()
+			opts.Complexity = true
+			report, err := walker.Analyze(r.Context(), opts)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			walker.RenderJSON(w, report)
+		})
+
+		fmt.Printf("Serving analysis of %s on http://%s/\n", cfg.Root, *addr)
+		return http.ListenAndServe(*addr, nil)
+	}
+}
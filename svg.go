@@ -0,0 +1,21 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/XanaOG/Walker/walker"
+)
+
+func writeBadges(dir string, report *walker.Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for _, b := range walker.Badges(report) {
+		if err := os.WriteFile(filepath.Join(dir, b.File), []byte(walker.RenderBadge(b)), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/XanaOG/Walker/walker"
+)
+
+func outputTemplate(report *walker.Report, config Config) error {
+	content, err := os.ReadFile(config.Template)
+	if err != nil {
+		return err
+	}
+
+	tmpl, err := walker.ParseTemplate(filepath.Base(config.Template), string(content))
+	if err != nil {
+		return err
+	}
+
+	return walker.RenderTemplate(os.Stdout, tmpl, report, config.TopFiles)
+}
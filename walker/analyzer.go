@@ -0,0 +1,81 @@
+package walker
+
+import (
+	"bytes"
+	"io"
+)
+
+// Analyzer analyzes the content of one file. Analyze calls it from every
+// worker, so implementations must be safe for concurrent use.
+//
+// An analyzer returns the usual counts in FileStats; measures that have no
+// field of their own go in FileStats.Metrics.
+type Analyzer interface {
+	// Analyze reads one file written in the language langConfig
+	// describes. path is only recorded in the result, and Size is the
+	// number of bytes read.
+	Analyze(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error)
+}
+
+// AnalyzerFunc adapts an ordinary function to Analyzer.
+type AnalyzerFunc func(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error)
+
+// Analyze calls f.
+func (f AnalyzerFunc) Analyze(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error) {
+	return f(r, path, langConfig)
+}
+
+// RegexAnalyzer classifies each line with the language's patterns. It is
+// used for every language without an analyzer of its own.
+type RegexAnalyzer struct{}
+
+// Analyze is AnalyzeReader.
+func (RegexAnalyzer) Analyze(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error) {
+	return AnalyzeReader(r, path, langConfig)
+}
+
+// GoAnalyzer counts lines like RegexAnalyzer, then parses the file with
+// go/parser for exact function and type counts and GoStats. A file that
+// doesn't parse keeps the pattern-based counts.
+type GoAnalyzer struct{}
+
+// Analyze implements Analyzer.
+func (GoAnalyzer) Analyze(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error) {
+	counter := &countingReader{r: r}
+	src, err := io.ReadAll(counter)
+	if err != nil {
+		return FileStats{Path: path, Size: counter.n}, err
+	}
+
+	stats, err := AnalyzeReader(bytes.NewReader(src), path, langConfig)
+	if err != nil {
+		return stats, err
+	}
+	refineGo(src, &stats)
+	stats.Finish()
+	return stats, nil
+}
+
+// builtinAnalyzers are the languages with more than pattern matching.
+var builtinAnalyzers = map[string]Analyzer{
+	"Go":   GoAnalyzer{},
+	"Rust": RustAnalyzer{},
+}
+
+// RegisterAnalyzer sets the analyzer for the named language, replacing any
+// built-in one. A nil analyzer restores RegexAnalyzer.
+func (r *Registry) RegisterAnalyzer(name string, analyzer Analyzer) {
+	if analyzer == nil {
+		delete(r.analyzers, name)
+		return
+	}
+	r.analyzers[name] = analyzer
+}
+
+// AnalyzerFor returns the analyzer for the named language.
+func (r *Registry) AnalyzerFor(name string) Analyzer {
+	if analyzer, ok := r.analyzers[name]; ok {
+		return analyzer
+	}
+	return RegexAnalyzer{}
+}
@@ -0,0 +1,383 @@
+package walker
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CloneOptions turns on duplicate code detection in Analyze.
+type CloneOptions struct {
+	// MinTokens is the shortest run of tokens reported as a clone. Zero
+	// means 50.
+	MinTokens int
+	// IgnoreIdentifiers treats every identifier as the same token, so
+	// copies whose variables were renamed still match.
+	IgnoreIdentifiers bool
+}
+
+const defaultCloneTokens = 50
+
+// CloneReport lists the duplicated code found across the tree.
+type CloneReport struct {
+	MinTokens         int          `json:"min_tokens"`
+	IgnoreIdentifiers bool         `json:"ignore_identifiers,omitempty"`
+	Groups            []CloneGroup `json:"groups"`
+}
+
+// CloneGroup is one run of tokens that appears in two or more places.
+type CloneGroup struct {
+	Tokens    int             `json:"tokens"`
+	Lines     int             `json:"lines"`
+	Instances []CloneInstance `json:"instances"`
+}
+
+// CloneInstance is where one copy of a clone lives.
+type CloneInstance struct {
+	Language  string `json:"language"`
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// DuplicationStats is how much of a language's code is part of a clone.
+// Percent is of its code lines.
+type DuplicationStats struct {
+	Lines   int
+	Percent float64
+}
+
+// cloneFile is what the clone index keeps of a file: a hash of every
+// window of MinTokens consecutive tokens and the line each token is on,
+// rather than the file's text.
+type cloneFile struct {
+	lang   string
+	path   string
+	hashes []uint64
+	lines  []int32
+}
+
+// cloneLocation is a window's position: a file index and a token offset.
+type cloneLocation struct {
+	file, pos int32
+}
+
+// cloneIndex collects files for clone detection as workers finish them.
+type cloneIndex struct {
+	opts  CloneOptions
+	mu    sync.Mutex
+	files []cloneFile
+}
+
+func newCloneIndex(opts *CloneOptions) *cloneIndex {
+	if opts == nil {
+		return nil
+	}
+	index := &cloneIndex{opts: *opts}
+	if index.opts.MinTokens <= 0 {
+		index.opts.MinTokens = defaultCloneTokens
+	}
+	return index
+}
+
+// cloneBase is the FNV prime, used both to hash tokens and as the
+// multiplier of the rolling window hash.
+const cloneBase = 1099511628211
+
+// cloneScanner tokenizes a file's code lines as the worker reads the file,
+// so the clone index needs no second read. Comment and blank lines are
+// left out, and so are whitespace and trailing comments within lines.
+type cloneScanner struct {
+	ignoreIdentifiers bool
+	langConfig        LanguageConfig
+	delimiters        []string
+
+	partial []byte
+	number  int32
+	tokens  []uint64
+	lines   []int32
+}
+
+func (index *cloneIndex) scanner(langConfig LanguageConfig) *cloneScanner {
+	return &cloneScanner{
+		ignoreIdentifiers: index.opts.IgnoreIdentifiers,
+		langConfig:        langConfig,
+		delimiters:        stringDelimiters(langConfig),
+	}
+}
+
+// Write scans every complete line in p and keeps the rest for the next
+// call. It never fails.
+func (s *cloneScanner) Write(p []byte) (int, error) {
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			s.partial = append(s.partial, p...)
+			return n, nil
+		}
+		line := p[:i]
+		if len(s.partial) > 0 {
+			line = append(s.partial, line...)
+		}
+		s.scanLine(string(bytes.TrimSuffix(line, []byte{'\r'})))
+		s.partial = s.partial[:0]
+		p = p[i+1:]
+	}
+}
+
+// readFile scans ref from the start, for a file whose stats came from a
+// cache rather than a read.
+func (s *cloneScanner) readFile(ref fileRef) {
+	file, err := ref.fsys.Open(ref.name)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	io.Copy(s, file)
+}
+
+// scanLine tokenizes one line. Only a line's kind matters here, so it
+// skips the rest of ClassifyLine.
+func (s *cloneScanner) scanLine(text string) {
+	s.number++
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" || matchPattern(trimmed, s.langConfig.CommentPatterns) != nil {
+		return
+	}
+	code := text[:trailingComment(text, s.delimiters, s.langConfig.CommentPatterns)]
+	scanTokens(code, s.delimiters, func(token string, kind tokenKind) {
+		if s.ignoreIdentifiers && kind == tokenWord && !halsteadKeywords[token] {
+			token = "$id"
+		}
+		s.tokens = append(s.tokens, hashToken(token))
+		s.lines = append(s.lines, s.number)
+	})
+}
+
+// add records the window hashes of a file scanned by s. A file too short
+// to hold one window is skipped. Safe for concurrent use.
+func (index *cloneIndex) add(path, lang string, s *cloneScanner) {
+	if len(s.partial) > 0 {
+		s.scanLine(string(s.partial))
+		s.partial = nil
+	}
+	tokens := s.tokens
+	size := index.opts.MinTokens
+	if len(tokens) < size {
+		return
+	}
+
+	// The window hash is sum(t[k] * cloneBase^(size-1-k)), rolled one
+	// token at a time. Arithmetic wraps modulo 2^64.
+	var top, hash uint64 = 1, 0
+	for k := 0; k < size; k++ {
+		hash = hash*cloneBase + tokens[k]
+		if k > 0 {
+			top *= cloneBase
+		}
+	}
+	hashes := make([]uint64, 0, len(tokens)-size+1)
+	hashes = append(hashes, hash)
+	for k := size; k < len(tokens); k++ {
+		hash = (hash-tokens[k-size]*top)*cloneBase + tokens[k]
+		hashes = append(hashes, hash)
+	}
+
+	index.mu.Lock()
+	index.files = append(index.files, cloneFile{lang: lang, path: path, hashes: hashes, lines: s.lines})
+	index.mu.Unlock()
+}
+
+// trailingComment returns where a comment at the end of a code line
+// starts, or len(line). A comment must follow whitespace, outside any
+// string, start with punctuation and match one of patterns right there.
+func trailingComment(line string, delimiters []string, patterns []*regexp.Regexp) int {
+	for i := 0; i < len(line); {
+		if open := delimiterAt(line, i, delimiters); open != "" {
+			i += len(open)
+			for i < len(line) && !strings.HasPrefix(line[i:], open) {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i += len(open)
+			continue
+		}
+		if c := line[i]; i > 0 && (line[i-1] == ' ' || line[i-1] == '\t') && c != ' ' && c != '\t' && !isWordByte(c) {
+			for _, pattern := range patterns {
+				if loc := pattern.FindStringIndex(line[i:]); loc != nil && loc[0] == 0 {
+					return i
+				}
+			}
+		}
+		i++
+	}
+	return len(line)
+}
+
+// hashToken is the 64-bit FNV-1a hash of token.
+func hashToken(token string) uint64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(token); i++ {
+		hash ^= uint64(token[i])
+		hash *= cloneBase
+	}
+	return hash
+}
+
+// groups finds every window shared by two or more places and grows each
+// into the longest run all of its copies share. It also returns the
+// number of duplicated code lines per language. Windows are matched by
+// hash alone; with 64-bit hashes a false match is vanishingly unlikely.
+func (index *cloneIndex) groups() ([]CloneGroup, map[string]int) {
+	files := index.files
+	// Workers finish in any order; sort so results are reproducible.
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	windows := 0
+	for _, file := range files {
+		windows += len(file.hashes)
+	}
+	locations := make(map[uint64][]cloneLocation, windows)
+	for f, file := range files {
+		for pos, hash := range file.hashes {
+			locations[hash] = append(locations[hash], cloneLocation{int32(f), int32(pos)})
+		}
+	}
+
+	size := index.opts.MinTokens
+	duplicated := make([]map[int32]bool, len(files))
+	var groups []CloneGroup
+	for f, file := range files {
+		for pos, hash := range file.hashes {
+			locs := locations[hash]
+			// Each group is reported once, from its first copy, and a run
+			// continuing from the previous window was reported with it.
+			if len(locs) < 2 || locs[0] != (cloneLocation{int32(f), int32(pos)}) {
+				continue
+			}
+			if pos > 0 && extends(locations[file.hashes[pos-1]], locs) {
+				continue
+			}
+
+			length := 1
+			for run := locs; ; length++ {
+				next := shifted(locations, files, run)
+				if !extends(run, next) {
+					break
+				}
+				run = next
+			}
+			instances := distinctInstances(locs, size+length-1)
+			if len(instances) < 2 {
+				continue
+			}
+
+			group := CloneGroup{Tokens: size + length - 1}
+			for _, loc := range instances {
+				clone := files[loc.file]
+				start, end := clone.lines[loc.pos], clone.lines[int(loc.pos)+group.Tokens-1]
+				group.Instances = append(group.Instances, CloneInstance{
+					Language:  clone.lang,
+					Path:      clone.path,
+					StartLine: int(start),
+					EndLine:   int(end),
+				})
+				if duplicated[loc.file] == nil {
+					duplicated[loc.file] = make(map[int32]bool)
+				}
+				for _, line := range clone.lines[loc.pos : int(loc.pos)+group.Tokens] {
+					duplicated[loc.file][line] = true
+				}
+			}
+			group.Lines = group.Instances[0].EndLine - group.Instances[0].StartLine + 1
+			groups = append(groups, group)
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Tokens != groups[j].Tokens {
+			return groups[i].Tokens > groups[j].Tokens
+		}
+		return len(groups[i].Instances) > len(groups[j].Instances)
+	})
+
+	lines := make(map[string]int)
+	for f, set := range duplicated {
+		lines[files[f].lang] += len(set)
+	}
+	return groups, lines
+}
+
+// shifted returns the locations sharing the window one token after
+// locs[0], or nil past the end of its file.
+func shifted(locations map[uint64][]cloneLocation, files []cloneFile, locs []cloneLocation) []cloneLocation {
+	first := files[locs[0].file]
+	pos := int(locs[0].pos) + 1
+	if pos >= len(first.hashes) {
+		return nil
+	}
+	return locations[first.hashes[pos]]
+}
+
+// extends reports whether next is prev with every location one token
+// further on, so the two windows belong to the same run.
+func extends(prev, next []cloneLocation) bool {
+	if len(prev) != len(next) || len(next) < 2 {
+		return false
+	}
+	for i := range prev {
+		if next[i] != (cloneLocation{prev[i].file, prev[i].pos + 1}) {
+			return false
+		}
+	}
+	return true
+}
+
+// distinctInstances drops copies that overlap an earlier copy in the same
+// file, as happens in long runs of repeated tokens.
+func distinctInstances(locs []cloneLocation, tokens int) []cloneLocation {
+	var instances []cloneLocation
+	for _, loc := range locs {
+		if n := len(instances); n > 0 && instances[n-1].file == loc.file && int(loc.pos) < int(instances[n-1].pos)+tokens {
+			continue
+		}
+		instances = append(instances, loc)
+	}
+	return instances
+}
+
+// findClones fills in report.Clones and each language's Duplication from
+// index, leaving out files Options.Dedupe dropped.
+func (report *Report) findClones(index *cloneIndex) {
+	if report.Deduplicated {
+		kept := make(map[string]bool)
+		for _, file := range report.Files() {
+			kept[file.Path] = true
+		}
+		files := index.files[:0]
+		for _, file := range index.files {
+			if kept[file.path] {
+				files = append(files, file)
+			}
+		}
+		index.files = files
+	}
+	groups, lines := index.groups()
+	report.Clones = &CloneReport{
+		MinTokens:         index.opts.MinTokens,
+		IgnoreIdentifiers: index.opts.IgnoreIdentifiers,
+		Groups:            groups,
+	}
+	for lang, langStats := range report.Languages {
+		langStats.Duplication = &DuplicationStats{
+			Lines:   lines[lang],
+			Percent: Percent(lines[lang], langStats.CodeLines),
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package walker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// cloneSource is a short function with prefix added to each of its
+// identifiers.
+func cloneSource(prefix string) string {
+	return strings.NewReplacer("ITEMS", prefix+"items", "ITEM", prefix+"item", "TOTAL", prefix+"total", "IDX", prefix+"i").Replace(`func ITEMSSum(ITEMS []int) int {
+	TOTAL := 0 // running sum
+	for IDX, ITEM := range ITEMS {
+		if ITEM > 10 {
+			TOTAL += ITEM * IDX
+		} else {
+			TOTAL -= ITEM
+		}
+	}
+	return TOTAL
+}
+`)
+}
+
+func analyzeClones(t *testing.T, files map[string]string, opts CloneOptions) *CloneReport {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, source := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(source)}
+	}
+	report, err := Analyze(context.Background(), Options{Root: "src", FS: fsys, Clones: &opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report.Clones
+}
+
+func TestFindClones(t *testing.T) {
+	files := map[string]string{
+		"a.go": "package a\n\n" + cloneSource(""),
+		"b.go": "package b\n\nimport \"fmt\"\n\n" + cloneSource("") + "\nvar x = fmt.Sprint(1)\n",
+		"c.go": "package c\n\nconst limit = 3\n\n" + cloneSource("my"),
+	}
+
+	tests := []struct {
+		name      string
+		opts      CloneOptions
+		instances []CloneInstance
+	}{
+		{
+			name: "exact copies",
+			opts: CloneOptions{MinTokens: 20},
+			instances: []CloneInstance{
+				{Language: "Go", Path: "src/a.go", StartLine: 3, EndLine: 13},
+				{Language: "Go", Path: "src/b.go", StartLine: 5, EndLine: 15},
+			},
+		},
+		{
+			name: "renamed copies",
+			opts: CloneOptions{MinTokens: 20, IgnoreIdentifiers: true},
+			instances: []CloneInstance{
+				{Language: "Go", Path: "src/a.go", StartLine: 3, EndLine: 13},
+				{Language: "Go", Path: "src/b.go", StartLine: 5, EndLine: 15},
+				{Language: "Go", Path: "src/c.go", StartLine: 5, EndLine: 15},
+			},
+		},
+		{
+			name: "shorter than the window",
+			opts: CloneOptions{MinTokens: 100},
+		},
+	}
+	for _, tt := range tests {
+		clones := analyzeClones(t, files, tt.opts)
+		if clones.MinTokens != tt.opts.MinTokens {
+			t.Errorf("%s: MinTokens = %d, want %d", tt.name, clones.MinTokens, tt.opts.MinTokens)
+		}
+		if tt.instances == nil {
+			if len(clones.Groups) != 0 {
+				t.Errorf("%s: got %d groups, want none", tt.name, len(clones.Groups))
+			}
+			continue
+		}
+		if len(clones.Groups) != 1 {
+			t.Fatalf("%s: got %d groups, want 1: %+v", tt.name, len(clones.Groups), clones.Groups)
+		}
+		group := clones.Groups[0]
+		if group.Tokens != 42 || group.Lines != 11 {
+			t.Errorf("%s: group is %d tokens, %d lines; want 42, 11", tt.name, group.Tokens, group.Lines)
+		}
+		if len(group.Instances) != len(tt.instances) {
+			t.Fatalf("%s: instances = %+v, want %+v", tt.name, group.Instances, tt.instances)
+		}
+		for i, want := range tt.instances {
+			if group.Instances[i] != want {
+				t.Errorf("%s: instance %d = %+v, want %+v", tt.name, i, group.Instances[i], want)
+			}
+		}
+	}
+}
+
+func TestDefaultCloneTokens(t *testing.T) {
+	files := map[string]string{
+		"a.go": cloneSource(""),
+		"b.go": cloneSource(""),
+	}
+	clones := analyzeClones(t, files, CloneOptions{})
+	if clones.MinTokens != defaultCloneTokens {
+		t.Errorf("MinTokens = %d, want %d", clones.MinTokens, defaultCloneTokens)
+	}
+	if len(clones.Groups) != 0 {
+		t.Errorf("a 42-token copy was reported with the default window: %+v", clones.Groups)
+	}
+}
+
+func TestCloneScannerSplitWrites(t *testing.T) {
+	langConfig, _ := DefaultRegistry().Lookup("Go")
+	index := newCloneIndex(&CloneOptions{MinTokens: 10})
+	source := "package a\r\n\r\n" + cloneSource("") + "var last = 1"
+
+	whole := index.scanner(langConfig)
+	whole.Write([]byte(source))
+	index.add("whole.go", "Go", whole)
+
+	split := index.scanner(langConfig)
+	for i := 0; i < len(source); i += 7 {
+		end := i + 7
+		if end > len(source) {
+			end = len(source)
+		}
+		split.Write([]byte(source[i:end]))
+	}
+	index.add("split.go", "Go", split)
+
+	if len(index.files) != 2 {
+		t.Fatalf("indexed %d files, want 2", len(index.files))
+	}
+	a, b := index.files[0], index.files[1]
+	if len(a.hashes) != len(b.hashes) || len(a.lines) != len(b.lines) {
+		t.Fatalf("split writes gave %d hashes, %d tokens; want %d, %d", len(b.hashes), len(b.lines), len(a.hashes), len(a.lines))
+	}
+	for i := range a.hashes {
+		if a.hashes[i] != b.hashes[i] {
+			t.Fatalf("window %d differs between whole and split writes", i)
+		}
+	}
+	if last := a.lines[len(a.lines)-1]; last != 14 {
+		t.Errorf("last token on line %d, want 14", last)
+	}
+}
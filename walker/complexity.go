@@ -0,0 +1,183 @@
+package walker
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FunctionStats describes one function found in a file.
+type FunctionStats struct {
+	Name string
+	// Line is where the function is declared and EndLine where it ends.
+	Line    int
+	EndLine int
+	// Lines is the length of the function, declaration to end.
+	Lines int
+	// MaxNesting is the deepest block nesting inside the function, with
+	// its body at depth 1.
+	MaxNesting int
+	// Complexity is the cyclomatic complexity: one plus the number of
+	// decision points in the function body.
+	Complexity int
+}
+
+// FunctionSummary summarizes one measure, such as cyclomatic complexity
+// or length, over a set of functions.
+type FunctionSummary struct {
+	Functions int
+	Max       int
+	Avg       float64
+	// P90 is the 90th percentile by the nearest-rank method.
+	P90 int
+}
+
+// FunctionResult pairs a function with the file and language it was found
+// in.
+type FunctionResult struct {
+	Language string
+	Path     string
+	FunctionStats
+}
+
+// defaultStringDelimiters are stripped from code lines before decision
+// points and blocks are counted, so an "if" or a brace inside a string
+// literal doesn't count.
+var defaultStringDelimiters = []string{`"`, `'`, "`"}
+
+var (
+	// functionKeywordName allows modifiers such as "pub" or "export async"
+	// before the keyword, and a Go method receiver after it.
+	functionKeywordName = regexp.MustCompile(`^\s*(?:\w+\s+)*?(?:def|fn|func|function|fun|sub|proc)\b\s*(?:\([^)]*\)\s*)?(\w+)`)
+	functionAssignName  = regexp.MustCompile(`\b(?:const|let|var)\s+(\w+)\s*=`)
+	functionCallName    = regexp.MustCompile(`(\w+)\s*(?:<[^<>()]*>)?\s*\(`)
+	functionObjectName  = regexp.MustCompile(`^\s*(\w+)\s*[:=]`)
+
+	// notFunctionNames are words functionCallName can land on that are
+	// keywords or modifiers rather than names.
+	notFunctionNames = map[string]bool{
+		"func": true, "function": true, "fn": true, "fun": true, "def": true,
+		"if": true, "for": true, "while": true, "switch": true, "catch": true,
+		"return": true, "new": true, "async": true, "await": true,
+	}
+)
+
+// functionName guesses the name declared on a line FunctionPattern
+// matched. It returns "" when no name can be found, as for anonymous
+// functions.
+func functionName(line string) string {
+	if m := functionKeywordName.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	if m := functionAssignName.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	for _, m := range functionCallName.FindAllStringSubmatch(line, -1) {
+		if !notFunctionNames[m[1]] {
+			return m[1]
+		}
+	}
+	if m := functionObjectName.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// stringDelimiters returns the delimiters stripStrings should use for
+// langConfig.
+func stringDelimiters(langConfig LanguageConfig) []string {
+	if langConfig.StringDelimiters != nil {
+		return langConfig.StringDelimiters
+	}
+	return defaultStringDelimiters
+}
+
+// stripStrings blanks out text between matching delimiters on one line.
+// Escaped delimiters are skipped. An unterminated string runs to the end of
+// the line.
+func stripStrings(line string, delimiters []string) string {
+	if !containsAny(line, delimiters) {
+		return line
+	}
+	var b strings.Builder
+	b.Grow(len(line))
+	for i := 0; i < len(line); {
+		open := ""
+		for _, d := range delimiters {
+			if d != "" && strings.HasPrefix(line[i:], d) {
+				open = d
+				break
+			}
+		}
+		if open == "" {
+			b.WriteByte(line[i])
+			i++
+			continue
+		}
+
+		b.WriteString(open)
+		i += len(open)
+		for i < len(line) && !strings.HasPrefix(line[i:], open) {
+			if line[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i < len(line) {
+			b.WriteString(open)
+			i += len(open)
+		}
+	}
+	return b.String()
+}
+
+// containsAny reports whether line contains any of the non-empty
+// delimiters. Most lines have no string in them and are left as they are.
+func containsAny(line string, delimiters []string) bool {
+	for _, d := range delimiters {
+		if d != "" && strings.Contains(line, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarize computes the max, mean and 90th percentile of values.
+func summarize(values []int) FunctionSummary {
+	if len(values) == 0 {
+		return FunctionSummary{}
+	}
+	sorted := append([]int(nil), values...)
+	sort.Ints(sorted)
+
+	sum := 0
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := int(math.Ceil(0.9*float64(len(sorted)))) - 1
+	return FunctionSummary{
+		Functions: len(sorted),
+		Max:       sorted[len(sorted)-1],
+		Avg:       float64(sum) / float64(len(sorted)),
+		P90:       sorted[rank],
+	}
+}
+
+// TopComplexFunctions returns the topN functions with the highest
+// cyclomatic complexity.
+func (r *Report) TopComplexFunctions(topN int) []FunctionResult {
+	return r.topFunctions(topN, func(fn FunctionResult) int { return fn.Complexity })
+}
+
+// dropComplexity zeroes the measures Options.Complexity asks for. An
+// analyzer may still work some out, such as GoAnalyzer's complexity from
+// the syntax tree, and a run without the option reports none of them.
+func (stats *FileStats) dropComplexity() {
+	for i := range stats.FunctionStats {
+		stats.FunctionStats[i].Complexity = 0
+	}
+	stats.Complexity = FunctionSummary{}
+	stats.Halstead = HalsteadStats{}
+	stats.Maintainability = 0
+}
@@ -0,0 +1,111 @@
+package walker
+
+import (
+	"strings"
+	"testing"
+)
+
+// analyzeSource runs AnalyzeReader over source as the named built-in
+// language.
+func analyzeSource(t *testing.T, lang, source string) FileStats {
+	t.Helper()
+	langConfig, ok := DefaultRegistry().Lookup(lang)
+	if !ok {
+		t.Fatalf("%s is not registered", lang)
+	}
+	stats, err := AnalyzeReader(strings.NewReader(source), "file", langConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestDecisionPoints(t *testing.T) {
+	tests := []struct {
+		lang string
+		line string
+		want int
+	}{
+		{"Go", `if a && b || c {`, 3},
+		{"Go", `for _, x := range xs {`, 1},
+		{"Go", `case 1, 2:`, 1},
+		{"Go", `x := "if a && b"`, 0},
+		{"Go", "s := `for || case`", 0},
+		{"Python", `if a and not b or c:`, 3},
+		{"Python", `elif x:`, 1},
+		{"Python", `except ValueError:`, 1},
+		{"Python", `notify = "if"`, 0},
+		{"JavaScript", `const y = a ? b : c ?? d;`, 2},
+		{"JavaScript", `} catch (e) {`, 1},
+		{"Ruby", `return x unless y`, 1},
+		{"Ruby", `when 1 then a && b`, 2},
+		{"Rust", `match x {`, 0},
+		{"Rust", `Some(v) => v,`, 1},
+		{"Rust", `Some(v) if v > 0 => v,`, 2},
+		{"C#", `var name = given ?? "none";`, 1},
+		{"Java", `} catch (IOException e) {`, 1},
+	}
+	for _, tt := range tests {
+		langConfig, ok := DefaultRegistry().Lookup(tt.lang)
+		if !ok {
+			t.Fatalf("%s is not registered", tt.lang)
+		}
+		if got := ClassifyLine(tt.line, langConfig).Decisions; got != tt.want {
+			t.Errorf("%s %q: %d decisions, want %d", tt.lang, tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFunctionComplexity(t *testing.T) {
+	tests := []struct {
+		lang   string
+		source string
+		want   map[string]int
+	}{
+		{
+			lang: "JavaScript",
+			source: `function outer(a) {
+  if (a) {
+    return 1;
+  }
+  function inner(b) {
+    while (b && a) {
+      b--;
+    }
+  }
+  return a || 2;
+}
+
+function flat() {
+  return 0;
+}
+`,
+			want: map[string]int{"outer": 3, "inner": 3, "flat": 1},
+		},
+		{
+			lang: "Python",
+			source: `def check(x):
+    if x > 1 and x < 9:
+        return True
+    for y in range(x):
+        pass
+    return False
+
+def empty():
+    pass
+`,
+			want: map[string]int{"check": 4, "empty": 1},
+		},
+	}
+	for _, tt := range tests {
+		stats := analyzeSource(t, tt.lang, tt.source)
+		if len(stats.FunctionStats) != len(tt.want) {
+			t.Fatalf("%s: found %d functions, want %d: %+v", tt.lang, len(stats.FunctionStats), len(tt.want), stats.FunctionStats)
+		}
+		for _, fn := range stats.FunctionStats {
+			if fn.Complexity != tt.want[fn.Name] {
+				t.Errorf("%s %s: complexity %d, want %d", tt.lang, fn.Name, fn.Complexity, tt.want[fn.Name])
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package walker
+
+import (
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// DuplicateFiles is a group of files with identical content. Paths are
+// sorted, and with Options.Dedupe only the first is counted.
+type DuplicateFiles struct {
+	Hash  string   `json:"hash"`
+	Size  int64    `json:"size"`
+	Lines int      `json:"lines"`
+	Paths []string `json:"paths"`
+}
+
+// Redundant is the number of bytes taken by every copy after the first.
+func (d DuplicateFiles) Redundant() int64 {
+	return d.Size * int64(len(d.Paths)-1)
+}
+
+// groupDuplicateFiles fills in r.DuplicateFiles from each file's Hash and,
+// with dedupe, drops every copy but the first from the language totals.
+// Empty files are never grouped.
+func (r *Report) groupDuplicateFiles(dedupe bool) {
+	byHash := make(map[string]*DuplicateFiles)
+	for _, file := range r.Files() {
+		if file.Hash == "" || file.Size == 0 {
+			continue
+		}
+		group := byHash[file.Hash]
+		if group == nil {
+			group = &DuplicateFiles{Hash: file.Hash, Size: file.Size, Lines: file.Lines}
+			byHash[file.Hash] = group
+		}
+		group.Paths = append(group.Paths, file.Path)
+	}
+
+	r.DuplicateFiles = nil
+	drop := make(map[string]bool)
+	for _, group := range byHash {
+		if len(group.Paths) < 2 {
+			continue
+		}
+		sort.Strings(group.Paths)
+		for _, path := range group.Paths[1:] {
+			drop[path] = true
+		}
+		r.DuplicateFiles = append(r.DuplicateFiles, *group)
+	}
+	sort.Slice(r.DuplicateFiles, func(i, j int) bool {
+		a, b := r.DuplicateFiles[i], r.DuplicateFiles[j]
+		if a.Redundant() != b.Redundant() {
+			return a.Redundant() > b.Redundant()
+		}
+		return a.Paths[0] < b.Paths[0]
+	})
+
+	if !dedupe || len(drop) == 0 {
+		return
+	}
+	r.Deduplicated = true
+	for lang, langStats := range r.Languages {
+		kept := &LanguageStats{FileStats: make([]FileStats, 0, len(langStats.FileStats))}
+		for _, file := range langStats.FileStats {
+			if drop[file.Path] {
+				continue
+			}
+			kept.add(file)
+			kept.FileStats = append(kept.FileStats, file)
+		}
+		if kept.Files == 0 {
+			delete(r.Languages, lang)
+			continue
+		}
+		r.Languages[lang] = kept
+	}
+}
+
+// sameFiles remembers the files a walk has visited, so a hard link or
+// symlink to one of them is only analyzed once. Files are compared with
+// os.SameFile, which only matches files on disk.
+type sameFiles map[int64][]fs.FileInfo
+
+// seen reports whether ref is a file already visited, and remembers it if
+// not. Archives and git revisions have no links, and stat their files by
+// opening them, so only a source that implements fs.StatFS is checked.
+func (s sameFiles) seen(ref fileRef) bool {
+	statFS, ok := ref.fsys.(fs.StatFS)
+	if !ok {
+		return false
+	}
+	info, err := statFS.Stat(ref.name)
+	if err != nil {
+		return false
+	}
+	for _, other := range s[info.Size()] {
+		if os.SameFile(info, other) {
+			return true
+		}
+	}
+	s[info.Size()] = append(s[info.Size()], info)
+	return false
+}
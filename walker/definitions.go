@@ -0,0 +1,167 @@
+package walker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// languageDefinition is one entry in a language definitions file. Every
+// field is optional when overriding a built-in language; only the fields
+// given replace the built-in values.
+type languageDefinition struct {
+	Extensions       []string    `yaml:"extensions"`
+	Filenames        []string    `yaml:"filenames"`
+	LineComments     []string    `yaml:"line_comments"`
+	BlockComments    [][2]string `yaml:"block_comments"`
+	CommentPatterns  []string    `yaml:"comment_patterns"`
+	StringDelimiters []string    `yaml:"string_delimiters"`
+	FunctionPattern  *string     `yaml:"function_pattern"`
+	ClassPattern     *string     `yaml:"class_pattern"`
+	DecisionPattern  *string     `yaml:"decision_pattern"`
+	BlockOpen        *string     `yaml:"block_open"`
+	BlockClose       *string     `yaml:"block_close"`
+	IndentBlocks     *bool       `yaml:"indent_blocks"`
+}
+
+type languageDefinitions struct {
+	Languages map[string]languageDefinition `yaml:"languages"`
+}
+
+// LoadDefinitionsFile reads a YAML (or JSON, which YAML accepts)
+// definitions file and merges it into the registry. Errors name the file.
+func (r *Registry) LoadDefinitionsFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := r.LoadDefinitions(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// LoadDefinitions reads language definitions from rd and merges them into
+// the registry. Nothing is registered unless every definition is valid.
+func (r *Registry) LoadDefinitions(rd io.Reader) error {
+	var defs languageDefinitions
+	dec := yaml.NewDecoder(rd)
+	dec.KnownFields(true)
+	if err := dec.Decode(&defs); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	// Build every entry before touching the map so a bad definition
+	// doesn't leave the registry half-updated.
+	names := make([]string, 0, len(defs.Languages))
+	for name := range defs.Languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	built := make(map[string]LanguageConfig, len(names))
+	for _, name := range names {
+		langConfig, err := r.buildLanguageConfig(name, defs.Languages[name])
+		if err != nil {
+			return fmt.Errorf("language %q: %w", name, err)
+		}
+		built[name] = langConfig
+	}
+
+	// Register claims extensions and file names, so definitions win over
+	// built-in languages that already use them.
+	for _, name := range names {
+		r.Register(name, built[name])
+	}
+	return nil
+}
+
+func (r *Registry) buildLanguageConfig(name string, def languageDefinition) (LanguageConfig, error) {
+	langConfig, exists := r.languages[name]
+
+	if def.Extensions != nil {
+		for _, ext := range def.Extensions {
+			if !strings.HasPrefix(ext, ".") {
+				return langConfig, fmt.Errorf("extension %q must start with '.'; list exact names under filenames", ext)
+			}
+		}
+		langConfig.Extensions = def.Extensions
+	}
+	if def.Filenames != nil {
+		langConfig.Filenames = def.Filenames
+	}
+	if !exists && len(langConfig.Extensions) == 0 && len(langConfig.Filenames) == 0 {
+		return langConfig, fmt.Errorf("new languages need at least one extension or filename")
+	}
+
+	if def.LineComments != nil || def.BlockComments != nil || def.CommentPatterns != nil {
+		var patterns []*regexp.Regexp
+		for _, token := range def.LineComments {
+			if token == "" {
+				return langConfig, fmt.Errorf("line_comments: empty token")
+			}
+			patterns = append(patterns, regexp.MustCompile(`^\s*`+regexp.QuoteMeta(token)))
+		}
+		for _, pair := range def.BlockComments {
+			if pair[0] == "" || pair[1] == "" {
+				return langConfig, fmt.Errorf("block_comments: each entry needs an opening and closing token")
+			}
+			patterns = append(patterns, regexp.MustCompile(`^\s*`+regexp.QuoteMeta(pair[0])))
+		}
+		for i, expr := range def.CommentPatterns {
+			pattern, err := regexp.Compile(expr)
+			if err != nil {
+				return langConfig, fmt.Errorf("comment_patterns[%d]: %w", i, err)
+			}
+			patterns = append(patterns, pattern)
+		}
+		langConfig.CommentPatterns = patterns
+		langConfig.BlockComments = def.BlockComments
+	}
+
+	if def.StringDelimiters != nil {
+		langConfig.StringDelimiters = def.StringDelimiters
+	}
+
+	var err error
+	if langConfig.FunctionPattern, err = compileOptional(def.FunctionPattern, langConfig.FunctionPattern); err != nil {
+		return langConfig, fmt.Errorf("function_pattern: %w", err)
+	}
+	if langConfig.ClassPattern, err = compileOptional(def.ClassPattern, langConfig.ClassPattern); err != nil {
+		return langConfig, fmt.Errorf("class_pattern: %w", err)
+	}
+	if langConfig.DecisionPattern, err = compileOptional(def.DecisionPattern, langConfig.DecisionPattern); err != nil {
+		return langConfig, fmt.Errorf("decision_pattern: %w", err)
+	}
+	if langConfig.BlockOpen, err = compileOptional(def.BlockOpen, langConfig.BlockOpen); err != nil {
+		return langConfig, fmt.Errorf("block_open: %w", err)
+	}
+	if langConfig.BlockClose, err = compileOptional(def.BlockClose, langConfig.BlockClose); err != nil {
+		return langConfig, fmt.Errorf("block_close: %w", err)
+	}
+	if def.IndentBlocks != nil {
+		langConfig.IndentBlocks = *def.IndentBlocks
+	}
+
+	return langConfig, nil
+}
+
+// compileOptional compiles expr when it was given, keeping current
+// otherwise. An empty expression disables the pattern.
+func compileOptional(expr *string, current *regexp.Regexp) (*regexp.Regexp, error) {
+	if expr == nil {
+		return current, nil
+	}
+	if *expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(*expr)
+}
@@ -0,0 +1,68 @@
+package walker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBlockComments(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.LoadDefinitions(strings.NewReader(`languages:
+  Pipeline:
+    extensions: [.pipeline]
+    line_comments: ["#"]
+    block_comments: [["/*", "*/"]]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	langConfig, ok := registry.Lookup("Pipeline")
+	if !ok {
+		t.Fatal("Pipeline is not registered")
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		kinds  string
+	}{
+		{
+			name:   "on one line",
+			source: "/* one line */\nstage('build')\n",
+			kinds:  "#c",
+		},
+		{
+			name:   "across lines",
+			source: "/*\n * stage('skipped')\n\n */\nstage('build')\n",
+			kinds:  "##b#c",
+		},
+		{
+			name:   "after code",
+			source: "stage('build') /* trailing */\nstage('test') /* opens\nstill code */\n",
+			kinds:  "ccc",
+		},
+		{
+			name:   "closed and reopened",
+			source: "/* a */ /* b\nc */ /* d\n*/\nstage('build')\n",
+			kinds:  "###c",
+		},
+		{
+			name:   "never closed",
+			source: "# note\n/* open\nstage('build')\n",
+			kinds:  "###",
+		},
+	}
+	kindCodes := map[LineKind]byte{LineCode: 'c', LineComment: '#', LineDoc: '#', LineBlank: 'b'}
+	for _, tt := range tests {
+		var kinds []byte
+		err := ScanLines(strings.NewReader(tt.source), langConfig, func(line Line) {
+			kinds = append(kinds, kindCodes[line.Kind])
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(kinds) != tt.kinds {
+			t.Errorf("%s: kinds %q, want %q", tt.name, kinds, tt.kinds)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package walker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// FileStatus says how a file differs between two reports.
+type FileStatus string
+
+const (
+	FileAdded   FileStatus = "added"
+	FileDeleted FileStatus = "deleted"
+	FileChanged FileStatus = "changed"
+)
+
+// LanguageDelta is the change in one language's counts between two
+// reports.
+type LanguageDelta struct {
+	Language     string `json:"language"`
+	Files        int    `json:"files"`
+	Lines        int    `json:"lines"`
+	CodeLines    int    `json:"code_lines"`
+	CommentLines int    `json:"comment_lines"`
+	BlankLines   int    `json:"blank_lines"`
+	// AddedFiles, DeletedFiles and ChangedFiles are only filled in by
+	// DiffReports, which matches files by path.
+	AddedFiles   int `json:"added_files"`
+	DeletedFiles int `json:"deleted_files"`
+	ChangedFiles int `json:"changed_files"`
+}
+
+// FileDelta is the change in one file's counts. Added files count up from
+// zero and deleted files down to zero.
+type FileDelta struct {
+	Path         string     `json:"path"`
+	Language     string     `json:"language"`
+	Status       FileStatus `json:"status"`
+	Lines        int        `json:"lines"`
+	CodeLines    int        `json:"code_lines"`
+	CommentLines int        `json:"comment_lines"`
+	BlankLines   int        `json:"blank_lines"`
+}
+
+// ReportDiff is everything that changed between two reports.
+type ReportDiff struct {
+	Languages []LanguageDelta `json:"languages"`
+	// Files lists added, deleted and changed files, largest absolute
+	// change in lines first. Files whose counts are unchanged are left
+	// out.
+	Files  []FileDelta   `json:"files"`
+	Totals LanguageDelta `json:"totals"`
+}
+
+// DiffLanguages reports b minus a for every language present in either,
+// largest absolute change in lines first.
+func DiffLanguages(a, b *Report) []LanguageDelta {
+	names := make(map[string]bool)
+	for lang := range a.Languages {
+		names[lang] = true
+	}
+	for lang := range b.Languages {
+		names[lang] = true
+	}
+
+	var deltas []LanguageDelta
+	for lang := range names {
+		var before, after LanguageStats
+		if a.Languages[lang] != nil {
+			before = *a.Languages[lang]
+		}
+		if b.Languages[lang] != nil {
+			after = *b.Languages[lang]
+		}
+		deltas = append(deltas, LanguageDelta{
+			Language:     lang,
+			Files:        after.Files - before.Files,
+			Lines:        after.Lines - before.Lines,
+			CodeLines:    after.CodeLines - before.CodeLines,
+			CommentLines: after.CommentLines - before.CommentLines,
+			BlankLines:   after.BlankLines - before.BlankLines,
+		})
+	}
+
+	sort.Slice(deltas, func(i, j int) bool {
+		if abs(deltas[i].Lines) != abs(deltas[j].Lines) {
+			return abs(deltas[i].Lines) > abs(deltas[j].Lines)
+		}
+		return deltas[i].Language < deltas[j].Language
+	})
+	return deltas
+}
+
+// DiffReports compares b against a per language and per file. Files are
+// matched by their path relative to each report's root, so two checkouts
+// in different directories, or two revisions, line up.
+func DiffReports(a, b *Report) *ReportDiff {
+	diff := &ReportDiff{Languages: DiffLanguages(a, b)}
+
+	before := filesByPath(a)
+	after := filesByPath(b)
+	for path, file := range after {
+		old, ok := before[path]
+		switch {
+		case !ok:
+			diff.Files = append(diff.Files, fileDelta(path, file.Language, FileAdded, FileStats{}, file.FileStats))
+		case old.Lines != file.Lines || old.CodeLines != file.CodeLines ||
+			old.CommentLines != file.CommentLines || old.BlankLines != file.BlankLines:
+			diff.Files = append(diff.Files, fileDelta(path, file.Language, FileChanged, old.FileStats, file.FileStats))
+		}
+	}
+	for path, file := range before {
+		if _, ok := after[path]; !ok {
+			diff.Files = append(diff.Files, fileDelta(path, file.Language, FileDeleted, file.FileStats, FileStats{}))
+		}
+	}
+
+	sort.Slice(diff.Files, func(i, j int) bool {
+		if abs(diff.Files[i].Lines) != abs(diff.Files[j].Lines) {
+			return abs(diff.Files[i].Lines) > abs(diff.Files[j].Lines)
+		}
+		return diff.Files[i].Path < diff.Files[j].Path
+	})
+
+	byLang := make(map[string]*LanguageDelta)
+	for i := range diff.Languages {
+		byLang[diff.Languages[i].Language] = &diff.Languages[i]
+	}
+	for _, file := range diff.Files {
+		d := byLang[file.Language]
+		if d == nil {
+			continue
+		}
+		switch file.Status {
+		case FileAdded:
+			d.AddedFiles++
+		case FileDeleted:
+			d.DeletedFiles++
+		default:
+			d.ChangedFiles++
+		}
+	}
+
+	diff.Totals.Language = "TOTAL"
+	for _, d := range diff.Languages {
+		diff.Totals.Files += d.Files
+		diff.Totals.Lines += d.Lines
+		diff.Totals.CodeLines += d.CodeLines
+		diff.Totals.CommentLines += d.CommentLines
+		diff.Totals.BlankLines += d.BlankLines
+		diff.Totals.AddedFiles += d.AddedFiles
+		diff.Totals.DeletedFiles += d.DeletedFiles
+		diff.Totals.ChangedFiles += d.ChangedFiles
+	}
+	return diff
+}
+
+func filesByPath(r *Report) map[string]FileResult {
+	files := make(map[string]FileResult)
+	for _, file := range r.Files() {
+		files[relativePath(r.Root, file.Path)] = file
+	}
+	return files
+}
+
+func fileDelta(path, lang string, status FileStatus, before, after FileStats) FileDelta {
+	return FileDelta{
+		Path:         path,
+		Language:     lang,
+		Status:       status,
+		Lines:        after.Lines - before.Lines,
+		CodeLines:    after.CodeLines - before.CodeLines,
+		CommentLines: after.CommentLines - before.CommentLines,
+		BlankLines:   after.BlankLines - before.BlankLines,
+	}
+}
+
+// DiffOptions controls RenderDiff.
+type DiffOptions struct {
+	// TopFiles is how many changed files to list. Zero hides the list.
+	TopFiles int
+}
+
+// RenderDiff writes the per-language deltas as a table with signed
+// counts, followed by the files that changed the most.
+func RenderDiff(w io.Writer, diff *ReportDiff, opts DiffOptions) {
+	if len(diff.Languages) == 0 {
+		color.New(color.FgYellow).Fprintln(w, "No supported code files found!")
+		return
+	}
+
+	fmt.Fprintf(w, "%-15s %8s %12s %12s %12s %8s %8s %8s %8s\n",
+		"LANGUAGE", "FILES", "LINES", "CODE", "COMMENTS", "BLANK", "ADDED", "DELETED", "CHANGED")
+	fmt.Fprintln(w, strings.Repeat("─", 100))
+	for _, d := range diff.Languages {
+		renderDeltaRow(w, d)
+	}
+	fmt.Fprintln(w, strings.Repeat("─", 100))
+	renderDeltaRow(w, diff.Totals)
+
+	if opts.TopFiles <= 0 || len(diff.Files) == 0 {
+		return
+	}
+	files := diff.Files
+	if len(files) > opts.TopFiles {
+		files = files[:opts.TopFiles]
+	}
+
+	color.New(color.FgCyan).Fprintf(w, "\n Top %d Changed Files:\n", len(files))
+	for _, f := range files {
+		status := string(f.Status)
+		switch f.Status {
+		case FileAdded:
+			status = color.GreenString("%-8s", status)
+		case FileDeleted:
+			status = color.RedString("%-8s", status)
+		default:
+			status = color.YellowString("%-8s", status)
+		}
+		fmt.Fprintf(w, " %s %-60s %8s lines %8s code\n",
+			status, TruncateString(f.Path, 60), signed(f.Lines), signed(f.CodeLines))
+	}
+}
+
+func renderDeltaRow(w io.Writer, d LanguageDelta) {
+	fmt.Fprintf(w, "%-15s %8s %12s %12s %12s %8s %8d %8d %8d\n",
+		TruncateString(d.Language, 15),
+		signed(d.Files),
+		signed(d.Lines),
+		signed(d.CodeLines),
+		signed(d.CommentLines),
+		signed(d.BlankLines),
+		d.AddedFiles,
+		d.DeletedFiles,
+		d.ChangedFiles)
+}
+
+// RenderDiffJSON writes the diff as a single indented JSON document.
+func RenderDiffJSON(w io.Writer, diff *ReportDiff) error {
+	output := *diff
+	// Empty lists encode as [] rather than null.
+	if output.Languages == nil {
+		output.Languages = []LanguageDelta{}
+	}
+	if output.Files == nil {
+		output.Files = []FileDelta{}
+	}
+	jsonData, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+func signed(n int) string {
+	if n > 0 {
+		return fmt.Sprintf("+%d", n)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,356 @@
+package walker
+
+import (
+	"sort"
+	"strings"
+)
+
+// BlockStyle is how a language marks where a block, and so a function,
+// ends.
+type BlockStyle int
+
+const (
+	// BlocksNone means functions can't be delimited; each one runs until
+	// the next declaration.
+	BlocksNone BlockStyle = iota
+	// BlocksDelimited blocks open and close with tokens such as braces or
+	// "do" and "end".
+	BlocksDelimited
+	// BlocksIndented blocks end when the indentation drops back, as in
+	// Python.
+	BlocksIndented
+)
+
+// blockStyle returns the style langConfig's block settings describe.
+func blockStyle(langConfig LanguageConfig) BlockStyle {
+	switch {
+	case langConfig.IndentBlocks:
+		return BlocksIndented
+	case langConfig.BlockOpen != nil && langConfig.BlockClose != nil:
+		return BlocksDelimited
+	default:
+		return BlocksNone
+	}
+}
+
+// BlockChange is how one code line changes the block nesting depth,
+// relative to the depth before it: Net once the line ends, and Low and
+// High the lowest and highest depth reached along the way. "} else {" is
+// {Net: 0, Low: -1, High: 0}.
+type BlockChange struct {
+	Net  int
+	Low  int
+	High int
+}
+
+// LengthBucket counts the functions whose length in lines is between Min
+// and Max inclusive. Max is zero for the last, unbounded bucket.
+type LengthBucket struct {
+	Min       int `json:"min"`
+	Max       int `json:"max,omitempty"`
+	Functions int `json:"functions"`
+}
+
+// lengthBucketLimits are the upper bounds of every bucket but the last.
+var lengthBucketLimits = []int{10, 25, 50, 100, 200}
+
+// blockChange measures how code, with strings already stripped, opens and
+// closes langConfig's blocks.
+func blockChange(code string, langConfig LanguageConfig) BlockChange {
+	if blockStyle(langConfig) != BlocksDelimited {
+		return BlockChange{}
+	}
+
+	type mark struct{ pos, delta int }
+	var marks []mark
+	for _, m := range langConfig.BlockOpen.FindAllStringIndex(code, -1) {
+		marks = append(marks, mark{m[0], 1})
+	}
+	for _, m := range langConfig.BlockClose.FindAllStringIndex(code, -1) {
+		marks = append(marks, mark{m[0], -1})
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].pos < marks[j].pos })
+
+	var change BlockChange
+	for _, m := range marks {
+		change.Net += m.delta
+		if change.Net < change.Low {
+			change.Low = change.Net
+		}
+		if change.Net > change.High {
+			change.High = change.Net
+		}
+	}
+	return change
+}
+
+// indentWidth is the width of line's leading whitespace, with tabs
+// advancing to the next multiple of eight as Python reads them.
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// openFunction is a function whose end hasn't been seen yet.
+type openFunction struct {
+	index int
+	// depth is the block depth, or for indented blocks the indent, of
+	// the declaration line, and level its nesting depth.
+	depth int
+	level int
+	// opened is set once the function's body block has been entered.
+	opened bool
+}
+
+// functionTracker follows block structure through a file to find where
+// each function ends.
+type functionTracker struct {
+	depth    int
+	open     []openFunction
+	lastCode int
+
+	// indents are the widths of the enclosing indented blocks.
+	indents    []int
+	lastIndent int
+	// steps counts how far space-indented lines step in.
+	steps     map[int]int
+	codeLines int
+	depthSum  int
+
+	// operators and operands are the distinct Halstead tokens seen.
+	operators map[string]bool
+	operands  map[string]bool
+	decisions int
+}
+
+// trackFunctions updates the open functions for a code line, adds its
+// decision points to the innermost one and records its nesting depth. A
+// function ends on the line that closes its body block, or for indented
+// blocks on the last code line before one indented no deeper than the
+// declaration. Without blocks a function ends at the next declaration.
+func (stats *FileStats) trackFunctions(line Line) {
+	t := &stats.tracker
+	stats.recordIndent(line)
+	switch line.BlockStyle {
+	case BlocksIndented:
+		// A line starting with a closing bracket finishes a multi-line
+		// signature or call rather than the block.
+		continuation := strings.IndexAny(strings.TrimSpace(line.Text), ")]}") == 0
+		level := t.indentLevel(line, continuation)
+		for len(t.open) > 0 && !continuation && line.Indent <= t.open[len(t.open)-1].depth {
+			stats.endFunction(t.lastCode)
+		}
+		if line.Function {
+			stats.startFunction(line, line.Indent, level, true)
+		}
+		stats.addDecisions(line)
+		stats.recordDepth(level)
+
+	case BlocksDelimited:
+		// A line that closes a block, "}" or "} else {", sits at the
+		// depth it closes to.
+		level := t.depth + line.Blocks.Low
+		if level < 0 {
+			level = 0
+		}
+		if line.Function {
+			// A declaration that never opened a body, such as a prototype
+			// or an expression-bodied function, ends before the next one.
+			for len(t.open) > 0 && !t.open[len(t.open)-1].opened {
+				stats.endFunction(t.lastCode)
+			}
+			stats.startFunction(line, t.depth, level, false)
+		}
+		stats.addDecisions(line)
+		stats.recordDepth(level)
+		stats.closeBlocks(line)
+
+	default:
+		if line.Function {
+			for len(t.open) > 0 {
+				stats.endFunction(t.lastCode)
+			}
+			stats.startFunction(line, 0, 0, true)
+		}
+		stats.addDecisions(line)
+		stats.recordDepth(0)
+	}
+	t.lastCode = line.Number
+}
+
+// addDecisions counts line's decision points towards the innermost open
+// function. Those outside any function aren't attributed.
+func (stats *FileStats) addDecisions(line Line) {
+	if t := &stats.tracker; len(t.open) > 0 {
+		stats.FunctionStats[t.open[len(t.open)-1].index].Complexity += line.Decisions
+	}
+}
+
+// closeBlocks applies a delimited-blocks line to the open functions.
+func (stats *FileStats) closeBlocks(line Line) {
+	t := &stats.tracker
+	change := line.Blocks
+	for len(t.open) > 0 {
+		top := &t.open[len(t.open)-1]
+		opening := stats.FunctionStats[top.index].Line == line.Number
+		if !top.opened {
+			if t.depth+change.High > top.depth {
+				top.opened = true
+				opening = true
+			} else if t.depth+change.Low < top.depth {
+				// The enclosing block closed first.
+				stats.endFunction(t.lastCode)
+				continue
+			} else if strings.HasSuffix(strings.TrimSpace(line.Text), ";") {
+				stats.endFunction(line.Number)
+				continue
+			} else {
+				break
+			}
+		}
+		low := change.Low
+		if opening {
+			// The body may open on this line, so only a net return to the
+			// declaration's depth closes it again.
+			low = change.Net
+		}
+		if t.depth+low > top.depth {
+			break
+		}
+		stats.endFunction(line.Number)
+	}
+	t.depth += change.Net
+	if t.depth < 0 {
+		t.depth = 0
+	}
+}
+
+func (stats *FileStats) startFunction(line Line, depth, level int, opened bool) {
+	stats.FunctionStats = append(stats.FunctionStats, FunctionStats{
+		Name:       line.FunctionName,
+		Line:       line.Number,
+		Complexity: 1,
+	})
+	stats.tracker.open = append(stats.tracker.open, openFunction{
+		index:  len(stats.FunctionStats) - 1,
+		depth:  depth,
+		level:  level,
+		opened: opened,
+	})
+}
+
+// endFunction closes the innermost open function at line end.
+func (stats *FileStats) endFunction(end int) {
+	t := &stats.tracker
+	top := t.open[len(t.open)-1]
+	t.open = t.open[:len(t.open)-1]
+	fn := &stats.FunctionStats[top.index]
+	if end < fn.Line {
+		end = fn.Line
+	}
+	fn.EndLine = end
+	fn.Lines = end - fn.Line + 1
+}
+
+// Finish ends any function still open at the end of the file and fills in
+// Complexity, Length, Nesting, Indent, Halstead and Maintainability.
+// AnalyzeReader calls it; callers feeding AddLine themselves should call it
+// after the last line, and again after replacing FunctionStats.
+func (stats *FileStats) Finish() {
+	for len(stats.tracker.open) > 0 {
+		stats.endFunction(stats.tracker.lastCode)
+	}
+	stats.finishNesting()
+	stats.finishHalstead()
+	stats.tracker = functionTracker{}
+	stats.Complexity, stats.Length = summarizeFunctions([]FileStats{*stats})
+}
+
+// summarizeFunctions summarizes the complexity and length of every
+// function in files.
+func summarizeFunctions(files []FileStats) (complexity, length FunctionSummary) {
+	var complexities, lengths []int
+	for _, file := range files {
+		for _, fn := range file.FunctionStats {
+			complexities = append(complexities, fn.Complexity)
+			lengths = append(lengths, fn.Lines)
+		}
+	}
+	return summarize(complexities), summarize(lengths)
+}
+
+// summarize fills in the language-wide figures that can only be worked out
+// once every file is in.
+func (r *Report) summarize() {
+	for _, langStats := range r.Languages {
+		langStats.Complexity, langStats.Length = summarizeFunctions(langStats.FileStats)
+		langStats.summarizeNesting()
+		langStats.Maintainability = averageMaintainability(langStats.FileStats)
+		if langStats.Go != nil {
+			countGoPackages(langStats.Go, langStats.FileStats)
+		}
+	}
+}
+
+// Functions returns every function found, with its file and language, in
+// no particular order.
+func (r *Report) Functions() []FunctionResult {
+	var functions []FunctionResult
+	for _, file := range r.Files() {
+		for _, fn := range file.FunctionStats {
+			functions = append(functions, FunctionResult{file.Language, file.Path, fn})
+		}
+	}
+	return functions
+}
+
+// LongestFunctions returns the topN functions with the most lines.
+func (r *Report) LongestFunctions(topN int) []FunctionResult {
+	return r.topFunctions(topN, func(fn FunctionResult) int { return fn.Lines })
+}
+
+// topFunctions returns the topN functions with the highest measure, ties
+// broken by path and line.
+func (r *Report) topFunctions(topN int, measure func(FunctionResult) int) []FunctionResult {
+	functions := r.Functions()
+	sort.Slice(functions, func(i, j int) bool {
+		if a, b := measure(functions[i]), measure(functions[j]); a != b {
+			return a > b
+		}
+		if functions[i].Path != functions[j].Path {
+			return functions[i].Path < functions[j].Path
+		}
+		return functions[i].Line < functions[j].Line
+	})
+	if len(functions) > topN {
+		functions = functions[:topN]
+	}
+	return functions
+}
+
+// FunctionLengths buckets every function by its length in lines.
+func (r *Report) FunctionLengths() []LengthBucket {
+	buckets := make([]LengthBucket, len(lengthBucketLimits)+1)
+	min := 1
+	for i, limit := range lengthBucketLimits {
+		buckets[i] = LengthBucket{Min: min, Max: limit}
+		min = limit + 1
+	}
+	buckets[len(lengthBucketLimits)] = LengthBucket{Min: min}
+
+	for _, fn := range r.Functions() {
+		i := sort.SearchInts(lengthBucketLimits, fn.Lines)
+		buckets[i].Functions++
+	}
+	return buckets
+}
@@ -0,0 +1,129 @@
+package walker
+
+import "testing"
+
+func TestFunctionBoundaries(t *testing.T) {
+	type span struct {
+		name       string
+		line, end  int
+		totalLines int
+	}
+	tests := []struct {
+		name   string
+		lang   string
+		source string
+		want   []span
+	}{
+		{
+			name: "braces",
+			lang: "JavaScript",
+			source: `function first() {
+  return "}";
+}
+
+function second(a) {
+  if (a) {
+    return { a: 1 };
+  }
+}
+`,
+			want: []span{{"first", 1, 3, 3}, {"second", 5, 9, 5}},
+		},
+		{
+			name: "brace on the next line",
+			lang: "C",
+			source: `int main(void)
+{
+    return 0;
+}
+`,
+			want: []span{{"main", 1, 4, 4}},
+		},
+		{
+			name: "nested functions",
+			lang: "JavaScript",
+			source: `function outer() {
+  function inner() {
+    return 1;
+  }
+  return inner();
+}
+`,
+			want: []span{{"outer", 1, 6, 6}, {"inner", 2, 4, 3}},
+		},
+		{
+			name: "indented blocks",
+			lang: "Python",
+			source: `def first(x):
+    if x:
+        return 1
+
+    return 2
+# trailing comment
+def second():
+    pass
+
+x = first(1)
+`,
+			want: []span{{"first", 1, 5, 5}, {"second", 7, 8, 2}},
+		},
+		{
+			name: "keyword blocks",
+			lang: "Ruby",
+			source: `def greet(name)
+  if name
+    puts name
+  end
+end
+
+def bye
+  puts "bye"
+end
+`,
+			want: []span{{"greet", 1, 5, 5}, {"bye", 7, 9, 3}},
+		},
+		{
+			name: "open at the end of the file",
+			lang: "Python",
+			source: `def last():
+    return 1
+`,
+			want: []span{{"last", 1, 2, 2}},
+		},
+	}
+	for _, tt := range tests {
+		stats := analyzeSource(t, tt.lang, tt.source)
+		if len(stats.FunctionStats) != len(tt.want) {
+			t.Fatalf("%s: found %d functions, want %d: %+v", tt.name, len(stats.FunctionStats), len(tt.want), stats.FunctionStats)
+		}
+		for i, want := range tt.want {
+			fn := stats.FunctionStats[i]
+			got := span{fn.Name, fn.Line, fn.EndLine, fn.Lines}
+			if got != want {
+				t.Errorf("%s: function %d = %+v, want %+v", tt.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"func (s *Server) Serve(l net.Listener) error {", "Serve"},
+		{"func main() {", "main"},
+		{"pub async fn fetch(url: &str) -> Result<()> {", "fetch"},
+		{"export async function load(path) {", "load"},
+		{"const handler = async (req) => {", "handler"},
+		{"  public static int Max(int a, int b) {", "Max"},
+		{"  render: function() {", "render"},
+		{"def __init__(self):", "__init__"},
+		{"function() {", ""},
+	}
+	for _, tt := range tests {
+		if got := functionName(tt.line); got != tt.want {
+			t.Errorf("functionName(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package walker
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// GeneratedRules decide which files were generated rather than written by
+// hand. A file is generated when its base name matches one of Patterns,
+// one of Markers matches a line of its leading comment block, or its lines
+// average more than MinifiedLineLength characters.
+type GeneratedRules struct {
+	// Patterns are matched against each file's base name with
+	// filepath.Match.
+	Patterns []string
+	// Markers are matched against each whole line, comment leader
+	// included, of the comments and blank lines a file starts with.
+	Markers []*regexp.Regexp
+	// HeaderBytes caps how much of each file is searched for that leading
+	// comment block. Zero means 8 KB.
+	HeaderBytes int
+	// MinifiedLineLength marks minified bundles. Zero turns the check off.
+	MinifiedLineLength int
+}
+
+const defaultHeaderBytes = 8 << 10
+
+// DefaultGeneratedRules returns the rules Analyze uses when
+// Options.Generated is nil: protobuf and other codegen output, lockfiles,
+// minified assets, and the usual "// Code generated ... DO NOT EDIT." and
+// @generated header comments.
+func DefaultGeneratedRules() GeneratedRules {
+	return GeneratedRules{
+		Patterns: []string{
+			"*.pb.go", "*.pb.gw.go", "*_pb2.py", "*_pb2_grpc.py", "*.pb.h", "*.pb.cc",
+			"*_generated.*", "*.generated.*", "*.g.dart", "*.freezed.dart", "*.designer.cs",
+			"*.min.js", "*.min.css", "*.bundle.js",
+			"package-lock.json", "npm-shrinkwrap.json", "pnpm-lock.yaml",
+		},
+		Markers: []*regexp.Regexp{
+			regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`),
+			regexp.MustCompile(`^\s*(//|#|/?\*+|--|<!--)\s*@generated\b`),
+			regexp.MustCompile(`^\s*//\s*<auto-generated`),
+			regexp.MustCompile(`(?i)^\s*(//|#|/?\*+|--|<!--)\s*this file (is|was) (auto(matically)?[- ]?)?generated\b`),
+		},
+		MinifiedLineLength: 500,
+	}
+}
+
+// matchName reports whether path's base name matches one of Patterns.
+func (g *GeneratedRules) matchName(path string) bool {
+	base := filepath.Base(path)
+	for _, pattern := range g.Patterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *GeneratedRules) headerBytes() int {
+	if g.HeaderBytes > 0 {
+		return g.HeaderBytes
+	}
+	return defaultHeaderBytes
+}
+
+// isGenerated applies the rules to a file's stats and header.
+func (g *GeneratedRules) isGenerated(stats FileStats, header []byte, langConfig LanguageConfig) bool {
+	if g.matchName(stats.Path) || g.matchMarkers(header, langConfig) {
+		return true
+	}
+	return g.MinifiedLineLength > 0 && stats.Lines > 0 && stats.Characters/stats.Lines > g.MinifiedLineLength
+}
+
+// matchMarkers reports whether one of Markers matches a line of the
+// comments and blank lines at the top of header, up to its first code
+// line. A leading #! line is skipped.
+func (g *GeneratedRules) matchMarkers(header []byte, langConfig LanguageConfig) bool {
+	classifier := lineClassifier{langConfig: langConfig}
+	scanner := bufio.NewScanner(bytes.NewReader(header))
+	for first := true; scanner.Scan(); first = false {
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if first && strings.HasPrefix(text, "#!") {
+			continue
+		}
+		if classifier.classify(text).Kind == LineCode {
+			return false
+		}
+		for _, marker := range g.Markers {
+			if marker.MatchString(text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headerWriter keeps the first max bytes written to it.
+type headerWriter struct {
+	buf []byte
+	max int
+}
+
+func (h *headerWriter) Write(p []byte) (int, error) {
+	if room := h.max - len(h.buf); room > 0 {
+		if len(p) > room {
+			h.buf = append(h.buf, p[:room]...)
+		} else {
+			h.buf = append(h.buf, p...)
+		}
+	}
+	return len(p), nil
+}
+
+// GeneratedStats counts a language's generated files. Subtract them from
+// the language totals for the hand-written code.
+type GeneratedStats struct {
+	Files     int
+	Lines     int
+	CodeLines int
+}
+
+func (g *GeneratedStats) add(file FileStats) {
+	g.Files++
+	g.Lines += file.Lines
+	g.CodeLines += file.CodeLines
+}
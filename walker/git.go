@@ -0,0 +1,367 @@
+package walker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// errNotInRevision is returned when the analyzed directory doesn't exist
+// in the requested revision.
+var errNotInRevision = errors.New("directory does not exist at this revision")
+
+// gitRevision is the tree of one commit read straight from a repository's
+// object database, so no checkout is needed. It shells out to git, which
+// already knows how to read packfiles, alternates and every object format.
+type gitRevision struct {
+	fsys   *treeFS
+	commit string
+	// prefix is the analyzed directory's path within the repository,
+	// with a trailing slash, or empty at the top level.
+	prefix string
+	attrs  *gitAttributes
+	blobs  *catFile
+}
+
+// openGitRevision resolves rev in the repository containing dir and
+// indexes the part of its tree under dir.
+func openGitRevision(ctx context.Context, dir, rev string) (*gitRevision, error) {
+	if rev == "" || strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+
+	prefix, err := git(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSpace(prefix)
+
+	commit, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown revision %q", rev)
+	}
+	commit = strings.TrimSpace(commit)
+
+	tree, err := git(ctx, dir, "rev-parse", "--verify", "--quiet", commit+":"+strings.TrimSuffix(prefix, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", strings.TrimSuffix(prefix, "/"), errNotInRevision)
+	}
+	tree = strings.TrimSpace(tree)
+
+	listing, err := git(ctx, dir, "ls-tree", "--full-tree", "-r", "-z", "-l", tree)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := startCatFile(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &gitRevision{fsys: newTreeFS(), commit: commit, prefix: prefix, blobs: blobs}
+	for _, entry := range strings.Split(listing, "\x00") {
+		if f := r.parseTreeEntry(entry); f != nil {
+			r.fsys.add(f)
+		}
+	}
+	r.fsys.sort()
+
+	r.attrs = r.loadAttributes()
+	return r, nil
+}
+
+// parseTreeEntry turns one line of ls-tree -l output,
+// "<mode> <type> <object> <size>\t<path>", into a file. Symlinks,
+// submodules and anything unparsable yield nil.
+func (r *gitRevision) parseTreeEntry(entry string) *treeFile {
+	meta, name, ok := strings.Cut(entry, "\t")
+	if !ok {
+		return nil
+	}
+	fields := strings.Fields(meta)
+	if len(fields) != 4 || fields[1] != "blob" || fields[0] == "120000" {
+		return nil
+	}
+	size, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	mode := fs.FileMode(0644)
+	if fields[0] == "100755" {
+		mode = 0755
+	}
+	object := fields[2]
+	return &treeFile{
+		name:   name,
+		size:   size,
+		mode:   mode,
+		object: object,
+		load:   func() ([]byte, error) { return r.blobs.read(object) },
+	}
+}
+
+// loadAttributes reads every .gitattributes that can apply to the
+// analyzed directory: those in the directory and its parents as well as
+// those below it.
+func (r *gitRevision) loadAttributes() *gitAttributes {
+	attrs := &gitAttributes{}
+
+	dirs := []string{""}
+	for dir := strings.TrimSuffix(r.prefix, "/"); dir != ""; dir = parentDir(dir) {
+		dirs = append(dirs, dir)
+	}
+	for name := range r.fsys.files {
+		if path.Base(name) == ".gitattributes" && path.Dir(name) != "." {
+			dirs = append(dirs, r.prefix+path.Dir(name))
+		}
+	}
+
+	for _, dir := range dirs {
+		file := ".gitattributes"
+		if dir != "" {
+			file = dir + "/" + file
+		}
+		data, err := r.blobs.read(r.commit + ":" + file)
+		if err != nil {
+			continue
+		}
+		attrs.parse(dir, data)
+	}
+	attrs.sort()
+	return attrs
+}
+
+func parentDir(dir string) string {
+	if parent := path.Dir(dir); parent != "." {
+		return parent
+	}
+	return ""
+}
+
+// Close stops the git process serving blob contents.
+func (r *gitRevision) Close() error {
+	return r.blobs.close()
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// catFile is a long-running git cat-file --batch. Requests are serialized
+// because the process answers them one at a time.
+type catFile struct {
+	mu  sync.Mutex
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+func startCatFile(ctx context.Context, dir string) (*catFile, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", dir, "cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return &catFile{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// read returns the contents of the blob named by object, which may be a
+// hash or any "<rev>:<path>" expression git understands.
+func (c *catFile) read(object string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintln(c.in, object); err != nil {
+		return nil, err
+	}
+	header, err := c.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	// "<object> <type> <size>", or "<object> missing".
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fs.ErrNotExist
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: bad header %q", header)
+	}
+
+	data := make([]byte, size+1) // contents are followed by a newline
+	if _, err := io.ReadFull(c.out, data); err != nil {
+		return nil, err
+	}
+	if fields[1] != "blob" {
+		return nil, errors.New(object + " is a " + fields[1])
+	}
+	return data[:size], nil
+}
+
+func (c *catFile) close() error {
+	c.in.Close()
+	return c.cmd.Wait()
+}
+
+// gitAttributes holds the rules of every .gitattributes read for a
+// revision. Only the attributes Walker acts on are interpreted: the
+// linguist-vendored, linguist-generated, linguist-documentation and
+// binary flags exclude a file, and linguist-language overrides detection.
+type gitAttributes struct {
+	rules []attributeRule
+}
+
+type attributeRule struct {
+	// dir is the directory of the .gitattributes file within the
+	// repository; patterns are relative to it.
+	dir     string
+	pattern string
+	attrs   map[string]string
+}
+
+// parse reads a .gitattributes file from dir. Set attributes are "true",
+// unset ones (-attr) are "false", and !attr removes an earlier value.
+func (a *gitAttributes) parse(dir string, data []byte) {
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		rule := attributeRule{dir: dir, pattern: fields[0], attrs: make(map[string]string)}
+		for _, field := range fields[1:] {
+			switch {
+			case strings.HasPrefix(field, "-"):
+				rule.attrs[field[1:]] = "false"
+			case strings.HasPrefix(field, "!"):
+				rule.attrs[field[1:]] = ""
+			default:
+				name, value, ok := strings.Cut(field, "=")
+				if !ok {
+					value = "true"
+				}
+				rule.attrs[name] = value
+			}
+		}
+		a.rules = append(a.rules, rule)
+	}
+}
+
+// sort puts rules from deeper directories last, so they win, as git does.
+// Rules from the same file keep their order.
+func (a *gitAttributes) sort() {
+	sort.SliceStable(a.rules, func(i, j int) bool {
+		return depth(a.rules[i].dir) < depth(a.rules[j].dir)
+	})
+}
+
+func depth(dir string) int {
+	if dir == "" {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// lookup returns the attributes set on name, a path from the top of the
+// repository.
+func (a *gitAttributes) lookup(name string) map[string]string {
+	result := make(map[string]string)
+	for _, rule := range a.rules {
+		rel := name
+		if rule.dir != "" {
+			if !strings.HasPrefix(name, rule.dir+"/") {
+				continue
+			}
+			rel = name[len(rule.dir)+1:]
+		}
+		if !matchAttributePattern(rule.pattern, rel) {
+			continue
+		}
+		for k, v := range rule.attrs {
+			if v == "" {
+				delete(result, k)
+			} else {
+				result[k] = v
+			}
+		}
+	}
+	return result
+}
+
+// excluded reports whether name is marked as vendored, generated,
+// documentation or binary.
+func (a *gitAttributes) excluded(name string) bool {
+	attrs := a.lookup(name)
+	for _, key := range []string{"linguist-vendored", "linguist-generated", "linguist-documentation", "binary"} {
+		if attrs[key] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// language returns the linguist-language override for name, if any.
+func (a *gitAttributes) language(name string) string {
+	return a.lookup(name)["linguist-language"]
+}
+
+// matchAttributePattern matches rel against a .gitattributes pattern. A
+// pattern without a slash matches the base name at any depth; otherwise
+// it is anchored to the attributes file's directory and ** matches any
+// number of directories.
+func matchAttributePattern(pattern, rel string) bool {
+	if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		matched, _ := path.Match(pattern, path.Base(rel))
+		return matched
+	}
+	return matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], name[0]); !matched {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,68 @@
+package walker
+
+import "testing"
+
+func TestMatchAttributePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		rel     string
+		want    bool
+	}{
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "service.go", false},
+		{"Makefile", "tools/Makefile", true},
+		{"/vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/a.go", false},
+		{"docs/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/**/*.md", "docs/guide/deep/intro.md", true},
+		{"**/gen/*.go", "a/b/gen/x.go", true},
+		{"**/gen/*.go", "gen/x.go", true},
+		{"**/gen/*.go", "a/gen/sub/x.go", false},
+	}
+	for _, tt := range tests {
+		if got := matchAttributePattern(tt.pattern, tt.rel); got != tt.want {
+			t.Errorf("matchAttributePattern(%q, %q) = %v, want %v", tt.pattern, tt.rel, got, tt.want)
+		}
+	}
+}
+
+func TestGitAttributes(t *testing.T) {
+	var attrs gitAttributes
+	attrs.parse("", []byte(`# comments and bare patterns are ignored
+*.pb.go linguist-generated
+third_party/** linguist-vendored
+*.inc linguist-language=PHP
+docs/** linguist-documentation
+*.bin binary
+`))
+	attrs.parse("third_party/kept", []byte("*.go -linguist-vendored\n"))
+	attrs.parse("docs", []byte("*.go !linguist-documentation\n"))
+	attrs.sort()
+
+	tests := []struct {
+		name     string
+		excluded bool
+		language string
+	}{
+		{"api/service.pb.go", true, ""},
+		{"api/service.go", false, ""},
+		{"third_party/lib/a.go", true, ""},
+		{"third_party/kept/a.go", false, ""},
+		{"third_party/kept/a.c", true, ""},
+		{"src/header.inc", false, "PHP"},
+		{"docs/intro.md", true, ""},
+		{"docs/example.go", false, ""},
+		{"assets/blob.bin", true, ""},
+	}
+	for _, tt := range tests {
+		if got := attrs.excluded(tt.name); got != tt.excluded {
+			t.Errorf("excluded(%q) = %v, want %v", tt.name, got, tt.excluded)
+		}
+		if got := attrs.language(tt.name); got != tt.language {
+			t.Errorf("language(%q) = %q, want %q", tt.name, got, tt.language)
+		}
+	}
+}
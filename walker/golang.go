@@ -0,0 +1,255 @@
+package walker
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// GoStats are the counts only a Go parser can give. A file's Package is
+// its package clause; a language's Packages is the number of distinct
+// packages, by directory and name, across its files.
+type GoStats struct {
+	Package    string `json:",omitempty"`
+	Packages   int    `json:",omitempty"`
+	Functions  int
+	Methods    int
+	Types      int
+	Structs    int
+	Interfaces int
+	Aliases    int
+	// Exported and Unexported count package-level identifiers: functions,
+	// methods, types, constants and variables. Test files aren't part of
+	// a package's API and aren't counted.
+	Exported   int
+	Unexported int
+	// Documented is how many exported identifiers have a doc comment.
+	Documented int
+	Tests      int
+	Benchmarks int
+	Fuzz       int
+	Examples   int
+}
+
+// DocCoverage is the percentage of exported identifiers with a doc
+// comment.
+func (g *GoStats) DocCoverage() float64 {
+	return Percent(g.Documented, g.Exported)
+}
+
+func (g *GoStats) add(file *GoStats) {
+	g.Functions += file.Functions
+	g.Methods += file.Methods
+	g.Types += file.Types
+	g.Structs += file.Structs
+	g.Interfaces += file.Interfaces
+	g.Aliases += file.Aliases
+	g.Exported += file.Exported
+	g.Unexported += file.Unexported
+	g.Documented += file.Documented
+	g.Tests += file.Tests
+	g.Benchmarks += file.Benchmarks
+	g.Fuzz += file.Fuzz
+	g.Examples += file.Examples
+}
+
+// refineGo replaces the pattern-based function and class counts with ones
+// from parsing src, and fills in stats.Go. Files that don't parse keep the
+// pattern-based counts.
+func refineGo(src []byte, stats *FileStats) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, stats.Path, src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return
+	}
+
+	g := &GoStats{Package: file.Name.Name}
+	isTest := strings.HasSuffix(stats.Path, "_test.go")
+	var functions []FunctionStats
+	classes := 0
+
+	ident := func(name string, doc *ast.CommentGroup) {
+		if name == "_" || isTest {
+			return
+		}
+		if !ast.IsExported(name) {
+			g.Unexported++
+			return
+		}
+		g.Exported++
+		if doc != nil {
+			g.Documented++
+		}
+	}
+
+	for _, decl := range file.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			name := decl.Name.Name
+			if decl.Recv != nil {
+				g.Methods++
+				receiver := receiverType(decl.Recv)
+				if ast.IsExported(receiver) {
+					ident(name, decl.Doc)
+				} else if !isTest {
+					// Methods of unexported types aren't reachable.
+					g.Unexported++
+				}
+				name = receiver + "." + name
+			} else {
+				g.Functions++
+				ident(name, decl.Doc)
+				if isTest {
+					switch {
+					case isTestFunc(name, "Test"):
+						g.Tests++
+					case isTestFunc(name, "Benchmark"):
+						g.Benchmarks++
+					case isTestFunc(name, "Fuzz"):
+						g.Fuzz++
+					case isTestFunc(name, "Example"):
+						g.Examples++
+					}
+				}
+			}
+
+			start, end := fset.Position(decl.Pos()).Line, fset.Position(decl.End()).Line
+			functions = append(functions, FunctionStats{
+				Name:       name,
+				Line:       start,
+				EndLine:    end,
+				Lines:      end - start + 1,
+				Complexity: goComplexity(decl),
+				MaxNesting: goNesting(decl),
+			})
+
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					g.Types++
+					switch {
+					case spec.Assign.IsValid():
+						g.Aliases++
+					case isStructType(spec.Type):
+						g.Structs++
+						classes++
+					case isInterfaceType(spec.Type):
+						g.Interfaces++
+						classes++
+					}
+					ident(spec.Name.Name, specDoc(decl, spec.Doc))
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						ident(name.Name, specDoc(decl, spec.Doc))
+					}
+				}
+			}
+		}
+	}
+
+	stats.Functions = len(functions)
+	stats.FunctionStats = functions
+	stats.Classes = classes
+	stats.Go = g
+}
+
+// receiverType returns the base type name of a method receiver.
+func receiverType(recv *ast.FieldList) string {
+	if len(recv.List) == 0 {
+		return ""
+	}
+	expr := recv.List[0].Type
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// specDoc returns a spec's doc comment, or its declaration's when the
+// declaration isn't grouped.
+func specDoc(decl *ast.GenDecl, doc *ast.CommentGroup) *ast.CommentGroup {
+	if doc == nil && !decl.Lparen.IsValid() {
+		return decl.Doc
+	}
+	return doc
+}
+
+func isStructType(expr ast.Expr) bool {
+	_, ok := expr.(*ast.StructType)
+	return ok
+}
+
+func isInterfaceType(expr ast.Expr) bool {
+	_, ok := expr.(*ast.InterfaceType)
+	return ok
+}
+
+// isTestFunc reports whether name is prefix followed by nothing or by a
+// word that doesn't start lower-case, the rule go test uses.
+func isTestFunc(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// goComplexity is one plus the branch points in a function, including
+// those in function literals inside it.
+func goComplexity(decl *ast.FuncDecl) int {
+	complexity := 1
+	ast.Inspect(decl, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt, *ast.RangeStmt:
+			complexity++
+		case *ast.CaseClause:
+			if n.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if n.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if n.Op == token.LAND || n.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+// countGoPackages sets Packages from the distinct directory and package
+// name pairs among files.
+func countGoPackages(g *GoStats, files []FileStats) {
+	packages := make(map[string]bool)
+	for _, file := range files {
+		if file.Go != nil {
+			dir := path.Dir(filepath.ToSlash(file.Path))
+			packages[dir+"\x00"+file.Go.Package] = true
+		}
+	}
+	g.Packages = len(packages)
+}
@@ -0,0 +1,256 @@
+package walker
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// HalsteadStats are Halstead's software science measures, computed from
+// the operator and operand tokens of code lines. Keywords and punctuation
+// are operators; identifiers, numbers and string literals are operands.
+//
+// For a language the token counts, Volume and Effort are sums over its
+// files and Difficulty is Effort / Volume. Distinct counts only make sense
+// within one file and are left zero.
+type HalsteadStats struct {
+	DistinctOperators int
+	DistinctOperands  int
+	Operators         int
+	Operands          int
+	Volume            float64
+	Difficulty        float64
+	Effort            float64
+}
+
+// halsteadKeywords are treated as operators in every language. Words not
+// listed are operands, which is close enough across the C, scripting and
+// ML families without a lexer per language.
+var halsteadKeywords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		if else elif elsif unless then for foreach while until do loop break continue
+		goto return yield switch case default when match select try catch except
+		finally raise throw throws rescue ensure with as in is not and or new delete
+		func function fn fun def sub proc lambda class struct interface enum trait
+		impl type module package namespace import from export require use using
+		var let const val static final public private protected internal extern
+		abstract virtual override async await go defer chan map range end begin`) {
+		halsteadKeywords[word] = true
+	}
+}
+
+// hasHalstead reports whether Halstead measures and the maintainability
+// index apply to langConfig's language: only to code with functions or
+// decisions to find, not to prose, markup or data, and only when the
+// analysis asked for them.
+func hasHalstead(langConfig LanguageConfig) bool {
+	if langConfig.skipComplexity {
+		return false
+	}
+	return langConfig.FunctionPattern != nil || langConfig.DecisionPattern != nil
+}
+
+// halsteadTokens splits a code line into operators and operands. Closing
+// brackets are skipped, since a bracket pair is one operator.
+func halsteadTokens(line string, delimiters []string) (operators, operands []string) {
+	scanTokens(line, delimiters, func(token string, kind tokenKind) {
+		switch {
+		case kind == tokenClose:
+		case kind == tokenOperator, kind == tokenWord && halsteadKeywords[token]:
+			operators = append(operators, token)
+		default:
+			operands = append(operands, token)
+		}
+	})
+	return operators, operands
+}
+
+// tokenKind is the lexical class scanTokens gives a token.
+type tokenKind int
+
+const (
+	tokenOperator tokenKind = iota
+	tokenWord
+	tokenNumber
+	tokenString
+	tokenClose
+)
+
+// scanTokens calls visit for each token of a code line in order: words
+// (identifiers and keywords), numbers, string literals and runs of
+// punctuation. An opening bracket is reported as the pair, such as "()",
+// and a closing one as tokenClose.
+func scanTokens(line string, delimiters []string, visit func(token string, kind tokenKind)) {
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case isWordByte(c):
+			start := i
+			// A number keeps its decimal point.
+			number := '0' <= c && c <= '9'
+			for i < len(line) && (isWordByte(line[i]) || number && line[i] == '.') {
+				i++
+			}
+			if number {
+				visit(line[start:i], tokenNumber)
+			} else {
+				visit(line[start:i], tokenWord)
+			}
+
+		case c == '(' || c == '[' || c == '{':
+			visit(string(c)+string(closingBracket(c)), tokenOperator)
+			i++
+
+		case c == ')' || c == ']' || c == '}':
+			visit(string(c), tokenClose)
+			i++
+
+		default:
+			if open := delimiterAt(line, i, delimiters); open != "" {
+				start := i
+				i += len(open)
+				for i < len(line) && !strings.HasPrefix(line[i:], open) {
+					if line[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				i += len(open)
+				if i > len(line) {
+					i = len(line)
+				}
+				visit(line[start:i], tokenString)
+				continue
+			}
+			if c == ',' || c == ';' {
+				visit(string(c), tokenOperator)
+				i++
+				continue
+			}
+			start := i
+			for i < len(line) && isOperatorByte(line[i]) && delimiterAt(line, i, delimiters) == "" {
+				i++
+			}
+			if i == start {
+				// Anything else, such as non-ASCII text outside a string.
+				i++
+				continue
+			}
+			visit(line[start:i], tokenOperator)
+		}
+	}
+}
+
+// isWordByte reports whether c can be part of an identifier or number.
+// Bytes of multi-byte UTF-8 sequences count, so non-ASCII identifiers stay
+// whole.
+func isWordByte(c byte) bool {
+	return c == '_' || c >= utf8.RuneSelf || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+func isOperatorByte(c byte) bool {
+	return strings.IndexByte("+-*/%=<>!&|^~?:.@#$\\", c) >= 0
+}
+
+func closingBracket(c byte) byte {
+	switch c {
+	case '(':
+		return ')'
+	case '[':
+		return ']'
+	default:
+		return '}'
+	}
+}
+
+// delimiterAt returns the string delimiter starting at line[i], or "".
+func delimiterAt(line string, i int, delimiters []string) string {
+	for _, d := range delimiters {
+		if d != "" && strings.HasPrefix(line[i:], d) {
+			return d
+		}
+	}
+	return ""
+}
+
+// recordTokens folds a code line's tokens into the file's Halstead counts.
+func (stats *FileStats) recordTokens(line Line) {
+	t := &stats.tracker
+	if t.operators == nil {
+		t.operators = make(map[string]bool)
+		t.operands = make(map[string]bool)
+	}
+	for _, op := range line.Operators {
+		t.operators[op] = true
+	}
+	for _, op := range line.Operands {
+		t.operands[op] = true
+	}
+	stats.Halstead.Operators += len(line.Operators)
+	stats.Halstead.Operands += len(line.Operands)
+	t.decisions += line.Decisions
+}
+
+// finishHalstead works out the Halstead measures and maintainability index
+// once every line has been seen. Like finishNesting it leaves the results
+// alone when the tracker is empty.
+func (stats *FileStats) finishHalstead() {
+	t := &stats.tracker
+	if t.codeLines == 0 {
+		return
+	}
+	h := &stats.Halstead
+	h.DistinctOperators, h.DistinctOperands = len(t.operators), len(t.operands)
+	if vocabulary := h.DistinctOperators + h.DistinctOperands; vocabulary > 0 {
+		h.Volume = float64(h.Operators+h.Operands) * math.Log2(float64(vocabulary))
+	}
+	if h.DistinctOperands > 0 {
+		h.Difficulty = float64(h.DistinctOperators) / 2 * float64(h.Operands) / float64(h.DistinctOperands)
+	}
+	h.Effort = h.Difficulty * h.Volume
+	stats.Maintainability = maintainabilityIndex(h.Volume, 1+t.decisions, stats.CodeLines)
+}
+
+// maintainabilityIndex is the classic 171 - 5.2 ln(V) - 0.23 G - 16.2
+// ln(LOC), from Halstead volume, cyclomatic complexity and code lines. It
+// is 0 when there is no code to measure and may be negative for very large
+// files.
+func maintainabilityIndex(volume float64, complexity, codeLines int) float64 {
+	if volume <= 0 || codeLines <= 0 {
+		return 0
+	}
+	return 171 - 5.2*math.Log(volume) - 0.23*float64(complexity) - 16.2*math.Log(float64(codeLines))
+}
+
+// addHalstead folds a file's measures into a language's.
+func (s *LanguageStats) addHalstead(file FileStats) {
+	s.Halstead.Operators += file.Halstead.Operators
+	s.Halstead.Operands += file.Halstead.Operands
+	s.Halstead.Volume += file.Halstead.Volume
+	s.Halstead.Effort += file.Halstead.Effort
+	if s.Halstead.Volume > 0 {
+		s.Halstead.Difficulty = s.Halstead.Effort / s.Halstead.Volume
+	}
+}
+
+// averageMaintainability is the mean maintainability index of files,
+// weighted by code lines. Files without Halstead measures are left out.
+func averageMaintainability(files []FileStats) float64 {
+	weighted, lines := 0.0, 0
+	for _, file := range files {
+		if file.Halstead.Volume == 0 {
+			continue
+		}
+		weighted += file.Maintainability * float64(file.CodeLines)
+		lines += file.CodeLines
+	}
+	if lines == 0 {
+		return 0
+	}
+	return weighted / float64(lines)
+}
@@ -0,0 +1,262 @@
+package walker
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryOptions controls a call to History.
+type HistoryOptions struct {
+	// Analysis is applied to every snapshot. Its Root is a directory in
+	// the repository and its Rev the branch whose first-parent history
+	// is walked; empty means HEAD. Progress and OnFile are ignored.
+	Analysis Options
+	// Since is the first sample time. Zero means the first commit.
+	Since time.Time
+	// Until is the last sample time. Zero means now.
+	Until time.Time
+	// Every is the interval between samples.
+	Every time.Duration
+	// Progress, when set, is called with the number of snapshots analyzed
+	// so far and the number sampled.
+	Progress func(done, total int)
+}
+
+// HistoryCounts are the counters tracked over time.
+type HistoryCounts struct {
+	Files        int `json:"files"`
+	Lines        int `json:"lines"`
+	CodeLines    int `json:"code_lines"`
+	CommentLines int `json:"comment_lines"`
+	BlankLines   int `json:"blank_lines"`
+}
+
+// HistoryPoint is the state of the tree at one sample time: the last
+// first-parent commit made at or before Date.
+type HistoryPoint struct {
+	Date       time.Time                `json:"date"`
+	Commit     string                   `json:"commit"`
+	CommitTime time.Time                `json:"commit_time"`
+	Languages  map[string]HistoryCounts `json:"languages"`
+	Totals     HistoryCounts            `json:"totals"`
+}
+
+type historyCommit struct {
+	hash string
+	time time.Time
+}
+
+// History samples the first-parent history of a branch every
+// opts.Every between Since and Until and analyzes each sampled commit.
+// Files are read straight from the object database, and a blob that is
+// unchanged since an earlier sample is not read again.
+//
+// When ctx is cancelled History returns the points finished so far
+// together with ctx.Err().
+func History(ctx context.Context, opts HistoryOptions) ([]HistoryPoint, error) {
+	if opts.Every <= 0 {
+		return nil, errors.New("history interval must be positive")
+	}
+	rev := opts.Analysis.Rev
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	commits, err := firstParentCommits(ctx, opts.Analysis.Root, rev)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	since, until := opts.Since, opts.Until
+	if since.IsZero() {
+		since = commits[0].time
+	}
+	if until.IsZero() {
+		until = time.Now()
+	}
+	var dates []time.Time
+	for t := since; t.Before(until); t = t.Add(opts.Every) {
+		dates = append(dates, t)
+	}
+	dates = append(dates, until)
+
+	// Pair each date with the last commit made at or before it. Dates
+	// before the first commit have nothing to sample.
+	type sample struct {
+		date   time.Time
+		commit historyCommit
+	}
+	var samples []sample
+	next := 0
+	for _, date := range dates {
+		for next < len(commits) && !commits[next].time.After(date) {
+			next++
+		}
+		if next > 0 {
+			samples = append(samples, sample{date, commits[next-1]})
+		}
+	}
+
+	analysis := opts.Analysis
+	analysis.Progress = nil
+	analysis.OnFile = nil
+	analysis.cache = &blobCache{stats: make(map[string]FileStats)}
+
+	var points []HistoryPoint
+	for i, s := range samples {
+		point := HistoryPoint{Date: s.date, Commit: s.commit.hash, CommitTime: s.commit.time}
+
+		if i > 0 && points[i-1].Commit == s.commit.hash {
+			// Nothing was committed in this interval.
+			point.Languages = points[i-1].Languages
+			point.Totals = points[i-1].Totals
+		} else {
+			analysis.Rev = s.commit.hash
+			report, err := Analyze(ctx, analysis)
+			if errors.Is(err, errNotInRevision) {
+				// The directory was deleted; record it as empty.
+				report, err = &Report{}, nil
+			}
+			if err != nil {
+				return points, err
+			}
+			point.Languages = make(map[string]HistoryCounts, len(report.Languages))
+			for lang, stats := range report.Languages {
+				point.Languages[lang] = historyCounts(*stats)
+			}
+			point.Totals = historyCounts(report.Totals())
+		}
+
+		points = append(points, point)
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(samples))
+		}
+	}
+	return points, nil
+}
+
+func historyCounts(stats LanguageStats) HistoryCounts {
+	return HistoryCounts{
+		Files:        stats.Files,
+		Lines:        stats.Lines,
+		CodeLines:    stats.CodeLines,
+		CommentLines: stats.CommentLines,
+		BlankLines:   stats.BlankLines,
+	}
+}
+
+// firstParentCommits lists rev's first-parent history, oldest first.
+func firstParentCommits(ctx context.Context, dir, rev string) ([]historyCommit, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	// Limiting to "." skips commits from before dir existed.
+	out, err := git(ctx, dir, "log", "--first-parent", "--reverse", "--format=%H %ct", rev, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []historyCommit
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		hash, unix, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		seconds, err := strconv.ParseInt(unix, 10, 64)
+		if err != nil {
+			continue
+		}
+		commits = append(commits, historyCommit{hash, time.Unix(seconds, 0)})
+	}
+	// Commit dates can run backwards after a rebase; sampling needs them
+	// in order.
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].time.Before(commits[j].time)
+	})
+	return commits, nil
+}
+
+// blobCache remembers the counts for each git blob analyzed as a given
+// language. Safe for concurrent use; a nil cache stores nothing.
+type blobCache struct {
+	mu    sync.Mutex
+	stats map[string]FileStats
+}
+
+func (c *blobCache) get(ref fileRef, lang string) (FileStats, bool) {
+	if c == nil || ref.object == "" {
+		return FileStats{}, false
+	}
+	c.mu.Lock()
+	stats, ok := c.stats[ref.object+"\x00"+lang]
+	c.mu.Unlock()
+	// The same content may live at another path in this snapshot.
+	stats.Path = ref.display
+	return stats, ok
+}
+
+func (c *blobCache) put(ref fileRef, lang string, stats FileStats) {
+	if c == nil || ref.object == "" {
+		return
+	}
+	c.mu.Lock()
+	c.stats[ref.object+"\x00"+lang] = stats
+	c.mu.Unlock()
+}
+
+// RenderHistoryCSV writes one row per language per point, followed by a
+// TOTAL row, in a long format that spreadsheets can pivot.
+func RenderHistoryCSV(w io.Writer, points []HistoryPoint) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"date", "commit", "language", "files", "lines", "code", "comments", "blank"})
+	for _, p := range points {
+		date := p.Date.Format(time.RFC3339)
+		row := func(lang string, c HistoryCounts) {
+			out.Write([]string{
+				date, p.Commit, lang,
+				strconv.Itoa(c.Files),
+				strconv.Itoa(c.Lines),
+				strconv.Itoa(c.CodeLines),
+				strconv.Itoa(c.CommentLines),
+				strconv.Itoa(c.BlankLines),
+			})
+		}
+
+		langs := make([]string, 0, len(p.Languages))
+		for lang := range p.Languages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			row(lang, p.Languages[lang])
+		}
+		row("TOTAL", p.Totals)
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// RenderHistoryJSON writes the points as a single indented JSON array.
+func RenderHistoryJSON(w io.Writer, points []HistoryPoint) error {
+	if points == nil {
+		points = []HistoryPoint{}
+	}
+	jsonData, err := json.MarshalIndent(points, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
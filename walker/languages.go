@@ -0,0 +1,567 @@
+package walker
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LanguageConfig describes how to recognise and analyze one language.
+// Patterns are matched line by line: CommentPatterns against the trimmed
+// line, FunctionPattern, ClassPattern and DecisionPattern against the line
+// as written. DecisionPattern matches each branch point counted towards
+// cyclomatic complexity, such as an if or a short-circuit operator; matches
+// inside StringDelimiters are ignored.
+//
+// Functions end where their body block does. BlockOpen and BlockClose
+// match the tokens that open and close a block, such as braces or "do" and
+// "end"; IndentBlocks instead ends blocks by dedenting. With neither, a
+// function runs until the next declaration.
+type LanguageConfig struct {
+	Extensions       []string
+	Filenames        []string
+	FunctionPattern  *regexp.Regexp
+	ClassPattern     *regexp.Regexp
+	DecisionPattern  *regexp.Regexp
+	BlockOpen        *regexp.Regexp
+	BlockClose       *regexp.Regexp
+	IndentBlocks     bool
+	CommentPatterns  []*regexp.Regexp
+	StringDelimiters []string
+
+	// BlockComments lists the opening and closing tokens of comments
+	// that may span lines. A comment line that starts with an opening
+	// token and doesn't close it makes every line up to the closing one
+	// a comment; CommentPatterns still decides the opening line.
+	BlockComments [][2]string
+
+	// skipComplexity leaves out DecisionPattern and the Halstead tokens;
+	// Analyze sets it unless Options.Complexity is set.
+	skipComplexity bool
+}
+
+// Block delimiters shared by several languages.
+var (
+	braceOpen  = regexp.MustCompile(`\{`)
+	braceClose = regexp.MustCompile(`\}`)
+	endClose   = regexp.MustCompile(`\bend\b`)
+)
+
+var builtinLanguages = map[string]LanguageConfig{
+	"Go": {
+		Extensions:      []string{".go"},
+		FunctionPattern: regexp.MustCompile(`^\s*func\s+(\w+|\([^)]*\)\s*\w+)\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*type\s+\w+\s+(struct|interface)`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|case)\b|&&|\|\|`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Python": {
+		Extensions:      []string{".py", ".pyw", ".pyx"},
+		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|elif|for|while|except|and|or)\b`),
+		IndentBlocks:    true,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+			regexp.MustCompile(`^\s*""".*?"""`),
+			regexp.MustCompile(`^\s*'''.*?'''`),
+		},
+	},
+	"JavaScript": {
+		Extensions:      []string{".js", ".jsx", ".mjs", ".cjs"},
+		FunctionPattern: regexp.MustCompile(`^\s*(function\s+\w+|const\s+\w+\s*=\s*\(|let\s+\w+\s*=\s*\(|var\s+\w+\s*=\s*\(|\w+\s*:\s*function|\w+\s*=>\s*)`),
+		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while|case|catch)\b|&&|\|\||\?\?|\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"TypeScript": {
+		Extensions:      []string{".ts", ".tsx"},
+		FunctionPattern: regexp.MustCompile(`^\s*(function\s+\w+|const\s+\w+\s*=\s*\(|let\s+\w+\s*=\s*\(|export\s+function|\w+\s*:\s*\(|\w+\s*=>\s*)`),
+		ClassPattern:    regexp.MustCompile(`^\s*(export\s+)?(abstract\s+)?class\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while|case|catch)\b|&&|\|\||\?\?|\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Java": {
+		Extensions:      []string{".java"},
+		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected|static|\s)*\s+\w+\s+\w+\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*(public|private|protected)?\s*(abstract\s+)?(class|interface)\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while|case|catch)\b|&&|\|\||\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"C": {
+		Extensions:      []string{".c", ".h"},
+		FunctionPattern: regexp.MustCompile(`^\s*\w+\s+\w+\s*\(`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while|case)\b|&&|\|\||\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"C++": {
+		Extensions:      []string{".cpp", ".cc", ".cxx", ".hpp", ".hxx"},
+		FunctionPattern: regexp.MustCompile(`^\s*(\w+\s+)*\w+\s+\w+\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*(class|struct)\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while|case|catch)\b|&&|\|\||\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"C#": {
+		Extensions:      []string{".cs"},
+		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected|internal|static|\s)*\s+\w+\s+\w+\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*(public|private|protected|internal)?\s*(abstract\s+)?(class|interface|struct)\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|foreach|while|case|catch)\b|&&|\|\||\?\?|\s\?\s`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Rust": {
+		Extensions:      []string{".rs"},
+		FunctionPattern: regexp.MustCompile(`^\s*(pub\s+)?fn\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*(pub\s+)?(struct|enum|trait)\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|for|while)\b|&&|\|\||=>`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		// Lifetimes such as 'a would read as character literals.
+		StringDelimiters: []string{`"`},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"PHP": {
+		Extensions:      []string{".php", ".phtml"},
+		FunctionPattern: regexp.MustCompile(`^\s*(public|private|protected)?\s*function\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*(abstract\s+)?(class|interface|trait)\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`^\s*#`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Ruby": {
+		Extensions:      []string{".rb", ".rbw"},
+		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
+		DecisionPattern: regexp.MustCompile(`\b(if|elsif|unless|while|until|for|when|rescue|and|or)\b|&&|\|\||\s\?\s`),
+		BlockOpen:       regexp.MustCompile(`^\s*(def|class|module|if|unless|while|until|for|case|begin)\b|\bdo\b(\s*\|[^|]*\|)?\s*$`),
+		BlockClose:      endClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Swift": {
+		Extensions:      []string{".swift"},
+		FunctionPattern: regexp.MustCompile(`^\s*(private|public|internal)?\s*func\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*(public|private|internal)?\s*(class|struct|protocol)\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Kotlin": {
+		Extensions:      []string{".kt", ".kts"},
+		FunctionPattern: regexp.MustCompile(`^\s*(private|public|internal|protected)?\s*fun\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*(public|private|internal|protected)?\s*(class|interface|object)\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Shell": {
+		Extensions:      []string{".sh", ".bash", ".zsh", ".fish"},
+		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*\(\s*\)\s*\{`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"HTML": {
+		Extensions: []string{".html", ".htm", ".xhtml"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`<!--.*?-->`),
+		},
+	},
+	"CSS": {
+		Extensions: []string{".css", ".scss", ".sass", ".less"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`/\*.*?\*/`),
+			regexp.MustCompile(`^\s*//`), // SCSS/Sass comments
+		},
+	},
+	"SQL": {
+		Extensions: []string{".sql"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*--`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"YAML": {
+		Extensions:   []string{".yml", ".yaml"},
+		IndentBlocks: true,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"JSON": {
+		Extensions: []string{".json"},
+	},
+	"XML": {
+		Extensions: []string{".xml", ".xsd", ".xsl"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`<!--.*?-->`),
+		},
+	},
+	"Markdown": {
+		Extensions: []string{".md", ".markdown"},
+	},
+	"TOML": {
+		Extensions: []string{".toml"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"INI": {
+		Extensions: []string{".ini", ".cfg", ".conf"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*[#;]`),
+		},
+	},
+	"Dart": {
+		Extensions:      []string{".dart"},
+		FunctionPattern: regexp.MustCompile(`^\s*(static\s+)?\w+\s+\w+\s*\(`),
+		ClassPattern:    regexp.MustCompile(`^\s*(abstract\s+)?class\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Scala": {
+		Extensions:      []string{".scala", ".sc"},
+		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*(class|object|trait)\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"Lua": {
+		Extensions:      []string{".lua"},
+		FunctionPattern: regexp.MustCompile(`^\s*(local\s+)?function\s+\w+`),
+		BlockOpen:       regexp.MustCompile(`\b(function|if|do)\b`),
+		BlockClose:      endClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*--`),
+		},
+	},
+	"Perl": {
+		Extensions:      []string{".pl", ".pm", ".perl"},
+		FunctionPattern: regexp.MustCompile(`^\s*sub\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"R": {
+		Extensions:      []string{".r", ".R", ".Rmd"},
+		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*<-\s*function`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"MATLAB": {
+		Extensions:      []string{".m", ".mlx"},
+		FunctionPattern: regexp.MustCompile(`^\s*function\s+.*=\s*\w+`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*%`),
+		},
+	},
+	"Julia": {
+		Extensions:      []string{".jl"},
+		FunctionPattern: regexp.MustCompile(`^\s*function\s+\w+`),
+		BlockOpen:       regexp.MustCompile(`^\s*(function|if|for|while|let|begin|(mutable\s+)?struct|module|macro|try|quote)\b|\bdo\b`),
+		BlockClose:      regexp.MustCompile(`(^|[;\s])end\b`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Haskell": {
+		Extensions:      []string{".hs", ".lhs"},
+		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*::`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*--`),
+		},
+	},
+	"Erlang": {
+		Extensions:      []string{".erl", ".hrl"},
+		FunctionPattern: regexp.MustCompile(`^\s*\w+\s*\(`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*%`),
+		},
+	},
+	"Elixir": {
+		Extensions:      []string{".ex", ".exs"},
+		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
+		BlockOpen:       regexp.MustCompile(`\bdo\s*(#.*)?$|\bfn\b`),
+		BlockClose:      endClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"F#": {
+		Extensions:      []string{".fs", ".fsx", ".fsi"},
+		FunctionPattern: regexp.MustCompile(`^\s*let\s+\w+`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`\(\*.*?\*\)`),
+		},
+	},
+	"OCaml": {
+		Extensions:      []string{".ml", ".mli"},
+		FunctionPattern: regexp.MustCompile(`^\s*let\s+\w+`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`\(\*.*?\*\)`),
+		},
+	},
+	"Assembly": {
+		Extensions: []string{".asm", ".s", ".S"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*[#;]`),
+		},
+	},
+	"Vim": {
+		Extensions:      []string{".vim", ".vimrc"},
+		FunctionPattern: regexp.MustCompile(`^\s*function!?\s+\w+`),
+		BlockOpen:       regexp.MustCompile(`^\s*fu(nction)?!?\s`),
+		BlockClose:      regexp.MustCompile(`^\s*endf(unction)?\b`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*"`),
+		},
+	},
+	"Batch": {
+		Extensions:      []string{".bat", ".cmd"},
+		FunctionPattern: regexp.MustCompile(`^\s*:\w+`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*rem\s`),
+			regexp.MustCompile(`^\s*::`),
+		},
+	},
+	"PowerShell": {
+		Extensions:      []string{".ps1", ".psm1", ".psd1"},
+		FunctionPattern: regexp.MustCompile(`^\s*function\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Dockerfile": {
+		Extensions: []string{".dockerfile"},
+		Filenames:  []string{"Dockerfile"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Terraform": {
+		Extensions: []string{".tf", ".tfvars"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+	"GraphQL": {
+		Extensions: []string{".graphql", ".gql"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Protobuf": {
+		Extensions: []string{".proto"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+		},
+	},
+	"CMake": {
+		Extensions:      []string{".cmake"},
+		Filenames:       []string{"CMakeLists.txt"},
+		FunctionPattern: regexp.MustCompile(`^\s*function\s*\(`),
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Makefile": {
+		Extensions: []string{".mk"},
+		Filenames:  []string{"Makefile", "makefile", "GNUmakefile"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*#`),
+		},
+	},
+	"Properties": {
+		Extensions: []string{".properties", ".env"},
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*[#!]`),
+		},
+	},
+	"Groovy": {
+		Extensions:      []string{".groovy", ".gradle"},
+		FunctionPattern: regexp.MustCompile(`^\s*def\s+\w+`),
+		ClassPattern:    regexp.MustCompile(`^\s*class\s+\w+`),
+		BlockOpen:       braceOpen,
+		BlockClose:      braceClose,
+		CommentPatterns: []*regexp.Regexp{
+			regexp.MustCompile(`^\s*//`),
+			regexp.MustCompile(`/\*.*?\*/`),
+		},
+	},
+}
+
+// DefaultExcludes are the patterns the CLI excludes unless told otherwise:
+// version control metadata, dependency and build directories, IDE files
+// and binaries.
+var DefaultExcludes = []string{
+	".git", ".svn", ".hg", ".bzr",
+	"node_modules", "vendor", "target", "build", "dist",
+	".idea", ".vscode", ".vs", "*.exe", "*.dll", "*.so", "*.dylib",
+	"*.jar", "*.war", "*.class", "*.pyc", "*.pyo", "__pycache__",
+	".DS_Store", "Thumbs.db",
+}
+
+// Registry maps language names to their configuration and analyzer, and
+// resolves file paths to languages. A Registry is not safe for concurrent
+// modification; finish registering languages before analyzing with it.
+type Registry struct {
+	languages map[string]LanguageConfig
+	analyzers map[string]Analyzer
+	byExt     map[string]string
+	byName    map[string]string
+}
+
+// NewRegistry returns a registry holding the built-in languages and
+// analyzers.
+func NewRegistry() *Registry {
+	r := &Registry{
+		languages: make(map[string]LanguageConfig, len(builtinLanguages)),
+		analyzers: make(map[string]Analyzer, len(builtinAnalyzers)),
+	}
+	for name, langConfig := range builtinLanguages {
+		r.languages[name] = langConfig
+	}
+	for name, analyzer := range builtinAnalyzers {
+		r.analyzers[name] = analyzer
+	}
+	r.reindex()
+	return r
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the shared registry of built-in languages used
+// when Options.Registry is nil. Callers that want to add languages should
+// build their own with NewRegistry rather than modify this one.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register adds or replaces a language. Its extensions and file names take
+// precedence over any other language already claiming them. The language's
+// analyzer is left as it is; see RegisterAnalyzer.
+func (r *Registry) Register(name string, langConfig LanguageConfig) {
+	for ext, owner := range r.byExt {
+		if owner == name {
+			delete(r.byExt, ext)
+		}
+	}
+	for filename, owner := range r.byName {
+		if owner == name {
+			delete(r.byName, filename)
+		}
+	}
+	r.languages[name] = langConfig
+	r.claim(name, langConfig)
+}
+
+// Lookup returns the configuration for the named language.
+func (r *Registry) Lookup(name string) (LanguageConfig, bool) {
+	langConfig, ok := r.languages[name]
+	return langConfig, ok
+}
+
+// Names returns every registered language name, sorted.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.languages))
+	for name := range r.languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Detect matches exact file names (Dockerfile, Makefile) before falling
+// back to the extension.
+func (r *Registry) Detect(path string) (string, bool) {
+	if lang, ok := r.byName[filepath.Base(path)]; ok {
+		return lang, true
+	}
+	lang, ok := r.byExt[strings.ToLower(filepath.Ext(path))]
+	return lang, ok
+}
+
+func (r *Registry) reindex() {
+	r.byExt = make(map[string]string)
+	r.byName = make(map[string]string)
+	for name, langConfig := range r.languages {
+		r.claim(name, langConfig)
+	}
+}
+
+func (r *Registry) claim(name string, langConfig LanguageConfig) {
+	for _, ext := range langConfig.Extensions {
+		r.byExt[strings.ToLower(ext)] = name
+	}
+	for _, filename := range langConfig.Filenames {
+		r.byName[filename] = name
+	}
+}
@@ -0,0 +1,245 @@
+package walker
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// LineKind is the classification given to a single line.
+type LineKind int
+
+const (
+	LineCode LineKind = iota
+	LineComment
+	LineDoc
+	LineBlank
+)
+
+func (k LineKind) String() string {
+	switch k {
+	case LineComment:
+		return "comment"
+	case LineDoc:
+		return "doc"
+	case LineBlank:
+		return "blank"
+	default:
+		return "code"
+	}
+}
+
+// Line records how a single line was classified and why. The counters
+// only need Kind, Function, Class and the function tracking fields; the
+// rest is there for debugging views such as the CLI's explain command.
+type Line struct {
+	Number         int
+	Text           string
+	Kind           LineKind
+	CommentPattern *regexp.Regexp
+	Function       bool
+	// FunctionName is the name declared when Function is set, if one
+	// could be found.
+	FunctionName string
+	Class        bool
+	// Decisions is the number of DecisionPattern matches on a code line.
+	Decisions int
+	// Indent is the width of the leading whitespace, with tabs advancing
+	// to the next multiple of eight.
+	Indent int
+	// BlockStyle is the language's, and Blocks how a code line opens and
+	// closes its blocks.
+	BlockStyle BlockStyle
+	Blocks     BlockChange
+	// Operators and Operands are a code line's Halstead tokens, left nil
+	// for languages without functions or decisions, such as data formats.
+	Operators []string
+	Operands  []string
+}
+
+// docCommentPatterns mark comment lines that are documentation rather
+// than remarks. They only refine lines a language's CommentPatterns
+// already matched, so doc lines still count as comments.
+var docCommentPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(///|//!|/\*\*|"""|''')`),
+	regexp.MustCompile(`^#'`),
+}
+
+// AnalyzeFile opens path and analyzes it as langConfig. Unreadable files
+// yield zero counts rather than an error, matching how Analyze treats
+// them.
+func AnalyzeFile(path string, langConfig LanguageConfig) FileStats {
+	file, err := os.Open(path)
+	if err != nil {
+		return FileStats{Path: path}
+	}
+	defer file.Close()
+
+	stats, _ := AnalyzeReader(file, path, langConfig)
+	if info, err := file.Stat(); err == nil {
+		stats.Size = info.Size()
+	}
+	return stats
+}
+
+// AnalyzeReader counts lines read from r as langConfig. path is only
+// recorded in the result. Size is the number of bytes read.
+func AnalyzeReader(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error) {
+	counter := &countingReader{r: r}
+	stats := FileStats{Path: path}
+	err := ScanLines(counter, langConfig, stats.AddLine)
+	stats.Size = counter.n
+	stats.Finish()
+	return stats, err
+}
+
+// AddLine folds one classified line into the file's counts. A function
+// declaration starts a new entry in FunctionStats; see Finish.
+func (stats *FileStats) AddLine(line Line) {
+	stats.Lines++
+	stats.Characters += len(line.Text) + 1
+
+	switch line.Kind {
+	case LineBlank:
+		stats.BlankLines++
+	case LineComment, LineDoc:
+		stats.CommentLines++
+	default:
+		stats.CodeLines++
+		if line.Function {
+			stats.Functions++
+		}
+		stats.recordTokens(line)
+		stats.trackFunctions(line)
+		if line.Class {
+			stats.Classes++
+		}
+	}
+}
+
+// ScanLines classifies each line read from r and hands it to visit.
+func ScanLines(r io.Reader, langConfig LanguageConfig, visit func(Line)) error {
+	number := 0
+	classifier := lineClassifier{langConfig: langConfig}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		number++
+		line := classifier.classify(scanner.Text())
+		line.Number = number
+		visit(line)
+	}
+	return scanner.Err()
+}
+
+// lineClassifier is ClassifyLine for consecutive lines of one file. It
+// follows the language's BlockComments across lines.
+type lineClassifier struct {
+	langConfig LanguageConfig
+	// open is the block comment the last line left open, if any.
+	open [2]string
+}
+
+func (c *lineClassifier) classify(text string) Line {
+	if c.open[1] != "" {
+		if !commentStillOpen(text, c.open) {
+			c.open = [2]string{}
+		}
+		if strings.TrimSpace(text) == "" {
+			return Line{Text: text, Kind: LineBlank}
+		}
+		return Line{Text: text, Kind: LineComment, Indent: indentWidth(text)}
+	}
+
+	line := ClassifyLine(text, c.langConfig)
+	if line.Kind != LineComment && line.Kind != LineDoc {
+		return line
+	}
+	trimmed := strings.TrimSpace(text)
+	for _, pair := range c.langConfig.BlockComments {
+		if strings.HasPrefix(trimmed, pair[0]) {
+			if commentStillOpen(trimmed[len(pair[0]):], pair) {
+				c.open = pair
+			}
+			break
+		}
+	}
+	return line
+}
+
+// commentStillOpen reports whether a block comment open at the start of
+// rest is still open at its end, after any closing and reopening.
+func commentStillOpen(rest string, pair [2]string) bool {
+	for {
+		end := strings.Index(rest, pair[1])
+		if end < 0 {
+			return true
+		}
+		rest = rest[end+len(pair[1]):]
+		start := strings.Index(rest, pair[0])
+		if start < 0 {
+			return false
+		}
+		rest = rest[start+len(pair[0]):]
+	}
+}
+
+// ClassifyLine classifies a single line in isolation. Number is left zero.
+func ClassifyLine(line string, langConfig LanguageConfig) Line {
+	info := Line{Text: line}
+
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" {
+		info.Kind = LineBlank
+		return info
+	}
+
+	info.Indent = indentWidth(line)
+	if pattern := matchPattern(trimmed, langConfig.CommentPatterns); pattern != nil {
+		info.Kind = LineComment
+		info.CommentPattern = pattern
+		if matchPattern(trimmed, docCommentPatterns) != nil {
+			info.Kind = LineDoc
+		}
+		return info
+	}
+
+	info.Kind = LineCode
+	info.Function = langConfig.FunctionPattern != nil && langConfig.FunctionPattern.MatchString(line)
+	if info.Function {
+		info.FunctionName = functionName(line)
+	}
+	info.BlockStyle = blockStyle(langConfig)
+	code := stripStrings(line, stringDelimiters(langConfig))
+	if langConfig.DecisionPattern != nil && !langConfig.skipComplexity {
+		info.Decisions = len(langConfig.DecisionPattern.FindAllStringIndex(code, -1))
+	}
+	info.Blocks = blockChange(code, langConfig)
+	if hasHalstead(langConfig) {
+		info.Operators, info.Operands = halsteadTokens(line, stringDelimiters(langConfig))
+	}
+	info.Class = langConfig.ClassPattern != nil && langConfig.ClassPattern.MatchString(line)
+	return info
+}
+
+// matchPattern returns the first pattern matching line, or nil.
+func matchPattern(line string, patterns []*regexp.Regexp) *regexp.Regexp {
+	for _, pattern := range patterns {
+		if pattern.MatchString(line) {
+			return pattern
+		}
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
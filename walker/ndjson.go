@@ -0,0 +1,100 @@
+package walker
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// NDJSONWriter streams one JSON object per line. Pass WriteFile as
+// Options.OnFile so each file is written as soon as it is analyzed, then
+// call WriteSummary once Analyze returns.
+type NDJSONWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+type ndjsonFileRecord struct {
+	Type         string                 `json:"type"`
+	Language     string                 `json:"language"`
+	Path         string                 `json:"path"`
+	Lines        int                    `json:"lines"`
+	CodeLines    int                    `json:"code_lines"`
+	CommentLines int                    `json:"comment_lines"`
+	BlankLines   int                    `json:"blank_lines"`
+	Characters   int                    `json:"characters"`
+	Functions    int                    `json:"functions"`
+	Classes      int                    `json:"classes"`
+	Size         int64                  `json:"size"`
+	Generated    bool                   `json:"generated,omitempty"`
+	Complexity   ndjsonComplexityRecord `json:"complexity"`
+}
+
+type ndjsonComplexityRecord struct {
+	Max int     `json:"max"`
+	Avg float64 `json:"avg"`
+	P90 int     `json:"p90"`
+}
+
+type ndjsonSummaryRecord struct {
+	Type        string                 `json:"type"`
+	GeneratedAt time.Time              `json:"generated_at"`
+	Revision    string                 `json:"revision,omitempty"`
+	Incomplete  bool                   `json:"incomplete,omitempty"`
+	Languages   map[string]int         `json:"languages"`
+	Summary     map[string]interface{} `json:"summary"`
+	Gates       *GateResults           `json:"quality_gates,omitempty"`
+}
+
+// NewNDJSONWriter returns a writer that encodes records to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteFile writes a "file" record. It is safe for concurrent use.
+func (w *NDJSONWriter) WriteFile(lang string, stats FileStats) {
+	w.write(ndjsonFileRecord{
+		Type:         "file",
+		Language:     lang,
+		Path:         stats.Path,
+		Lines:        stats.Lines,
+		CodeLines:    stats.CodeLines,
+		CommentLines: stats.CommentLines,
+		BlankLines:   stats.BlankLines,
+		Characters:   stats.Characters,
+		Functions:    stats.Functions,
+		Classes:      stats.Classes,
+		Size:         stats.Size,
+		Generated:    stats.Generated,
+		Complexity: ndjsonComplexityRecord{
+			Max: stats.Complexity.Max,
+			Avg: stats.Complexity.Avg,
+			P90: stats.Complexity.P90,
+		},
+	})
+}
+
+// WriteSummary writes the closing "summary" record.
+func (w *NDJSONWriter) WriteSummary(report *Report) {
+	files := make(map[string]int, len(report.Languages))
+	for lang, langStats := range report.Languages {
+		files[lang] = langStats.Files
+	}
+	w.write(ndjsonSummaryRecord{
+		Type:        "summary",
+		GeneratedAt: report.GeneratedAt,
+		Revision:    report.Revision,
+		Incomplete:  report.Incomplete,
+		Languages:   files,
+		Summary:     summary(report.Totals()),
+		Gates:       report.QualityGates,
+	})
+}
+
+func (w *NDJSONWriter) write(record interface{}) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Encode appends the newline that delimits records.
+	w.enc.Encode(record)
+}
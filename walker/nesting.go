@@ -0,0 +1,170 @@
+package walker
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// NestingStats summarizes the block nesting depth of code lines: brace or
+// keyword depth for languages with delimited blocks, indentation level for
+// indented ones. Top-level code is at depth 0.
+type NestingStats struct {
+	Max int
+	Avg float64
+}
+
+// Indentation styles reported in IndentStats.Style.
+const (
+	IndentTabs   = "tabs"
+	IndentSpaces = "spaces"
+	IndentMixed  = "mixed"
+)
+
+// IndentStats describes how code lines are indented. Style is empty when
+// no line is indented, and Width is the most common step between
+// space-indented levels.
+type IndentStats struct {
+	Style      string
+	Width      int
+	TabLines   int
+	SpaceLines int
+}
+
+// recordIndent counts how a code line is indented and, for spaces, how
+// far it steps in from the previous code line.
+func (stats *FileStats) recordIndent(line Line) {
+	t := &stats.tracker
+	switch {
+	case strings.HasPrefix(line.Text, "\t"):
+		stats.Indent.TabLines++
+	case strings.HasPrefix(line.Text, " "):
+		stats.Indent.SpaceLines++
+		if step := line.Indent - t.lastIndent; step > 0 {
+			if t.steps == nil {
+				t.steps = make(map[int]int)
+			}
+			t.steps[step]++
+		}
+	}
+	t.lastIndent = line.Indent
+}
+
+// indentLevel returns the indentation level of a line in a language with
+// indented blocks. Lines continuing a bracketed expression keep the
+// current level.
+func (t *functionTracker) indentLevel(line Line, continuation bool) int {
+	if !continuation {
+		for len(t.indents) > 0 && line.Indent < t.indents[len(t.indents)-1] {
+			t.indents = t.indents[:len(t.indents)-1]
+		}
+		if line.Indent > 0 && (len(t.indents) == 0 || line.Indent > t.indents[len(t.indents)-1]) {
+			t.indents = append(t.indents, line.Indent)
+		}
+	}
+	return len(t.indents)
+}
+
+// recordDepth folds a code line's nesting depth into the file and every
+// open function. A function's own body is at depth 1.
+func (stats *FileStats) recordDepth(depth int) {
+	t := &stats.tracker
+	t.codeLines++
+	t.depthSum += depth
+	if depth > stats.Nesting.Max {
+		stats.Nesting.Max = depth
+	}
+	for _, open := range t.open {
+		fn := &stats.FunctionStats[open.index]
+		if d := depth - open.level; d > fn.MaxNesting {
+			fn.MaxNesting = d
+		}
+	}
+}
+
+// finishNesting fills in the averages and indent style once every line has
+// been seen. It does nothing when no code line was recorded, so Finish can
+// run again after the tracker is reset.
+func (stats *FileStats) finishNesting() {
+	t := &stats.tracker
+	if t.codeLines == 0 {
+		return
+	}
+	stats.Nesting.Avg = float64(t.depthSum) / float64(t.codeLines)
+	stats.Indent.Style = indentStyle(stats.Indent.TabLines, stats.Indent.SpaceLines)
+	stats.Indent.Width = mostCommon(t.steps)
+}
+
+func indentStyle(tabs, spaces int) string {
+	switch {
+	case tabs > 0 && spaces > 0:
+		return IndentMixed
+	case tabs > 0:
+		return IndentTabs
+	case spaces > 0:
+		return IndentSpaces
+	default:
+		return ""
+	}
+}
+
+// mostCommon returns the key with the highest count, preferring the
+// smaller key on a tie, or 0 for an empty map.
+func mostCommon(counts map[int]int) int {
+	best, bestCount := 0, 0
+	for key, count := range counts {
+		if count > bestCount || count == bestCount && key < best {
+			best, bestCount = key, count
+		}
+	}
+	return best
+}
+
+// summarizeNesting fills in a language's nesting and indentation from its
+// files. The average is weighted by code lines.
+func (s *LanguageStats) summarizeNesting() {
+	weighted, lines := 0.0, 0
+	widths := make(map[int]int)
+	for _, file := range s.FileStats {
+		weighted += file.Nesting.Avg * float64(file.CodeLines)
+		lines += file.CodeLines
+		if file.Indent.Width > 0 {
+			widths[file.Indent.Width]++
+		}
+	}
+	s.Nesting.Avg = 0
+	if lines > 0 {
+		s.Nesting.Avg = weighted / float64(lines)
+	}
+	s.Indent.Style = indentStyle(s.Indent.TabLines, s.Indent.SpaceLines)
+	s.Indent.Width = mostCommon(widths)
+}
+
+// DeepestFunctions returns the topN functions with the deepest nesting.
+func (r *Report) DeepestFunctions(topN int) []FunctionResult {
+	return r.topFunctions(topN, func(fn FunctionResult) int { return fn.MaxNesting })
+}
+
+// goNesting is the deepest block nesting in a function, with its body at
+// depth 1, counting the bodies of function literals inside it.
+func goNesting(decl *ast.FuncDecl) int {
+	var stack []ast.Node
+	depth, deepest := 0, 0
+	ast.Inspect(decl, func(n ast.Node) bool {
+		if n == nil {
+			if _, ok := stack[len(stack)-1].(*ast.BlockStmt); ok {
+				depth--
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		if _, ok := n.(*ast.BlockStmt); ok {
+			depth++
+			if depth > deepest {
+				deepest = depth
+			}
+		}
+		return true
+	})
+	return deepest
+}
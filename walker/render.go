@@ -0,0 +1,455 @@
+package walker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// TableOptions controls RenderTable.
+type TableOptions struct {
+	// TopFiles is how many of the largest files, and of the groups of
+	// identical files, to list. Zero hides both lists.
+	TopFiles int
+	// TopComplex is how many of the most complex functions to list,
+	// after a per-language complexity summary. Zero hides both.
+	TopComplex int
+	// TopLong is how many of the longest functions to list, after a
+	// histogram of function lengths. Zero hides both.
+	TopLong int
+	// TopNested is how many of the most deeply nested functions to list,
+	// after per-language nesting and indentation. Zero hides both.
+	TopNested int
+	// TopClones is how many of the largest clone groups to list, after
+	// the duplication per language, when the report has Clones.
+	TopClones int
+	// ByDirectory adds a rollup of every directory with its test and
+	// production code.
+	ByDirectory bool
+}
+
+// RenderTable writes the colourised per-language table, the largest files
+// and a short summary.
+func RenderTable(w io.Writer, report *Report, opts TableOptions) {
+	if len(report.Languages) == 0 {
+		if report.Incomplete {
+			color.New(color.FgYellow).Fprintln(w, "The analysis stopped before any file was analyzed.")
+			return
+		}
+		color.New(color.FgYellow).Fprintln(w, "No supported code files found!")
+		return
+	}
+
+	color.New(color.FgCyan).Fprintln(w, "\nCode Analysis Results")
+	fmt.Fprintf(w, "%s\n", color.New(color.FgHiBlack).Sprintf("Generated on: %s", report.GeneratedAt.Format("2006-01-02 15:04:05")))
+	if report.Revision != "" {
+		fmt.Fprintf(w, "%s\n", color.New(color.FgHiBlack).Sprintf("Revision: %s", report.Revision))
+	}
+	if report.Incomplete {
+		color.New(color.FgYellow).Fprintln(w, "Partial results: the analysis stopped before every file was read")
+	}
+	fmt.Fprintln(w)
+
+	// Print clean, well-formatted table
+	fmt.Fprintf(w, "%-15s %8s %12s %12s %12s %8s %12s %8s %10s %10s %10s\n",
+		"LANGUAGE", "FILES", "LINES", "CODE", "COMMENTS", "BLANK", "CHARS", "FUNCS", "CLASSES", "TEST", "PROD")
+
+	fmt.Fprintln(w, strings.Repeat("─", 142))
+
+	for _, item := range report.SortedLanguages() {
+		fmt.Fprintf(w, "%-15s %8d %12d %12d %12d %8d %12d %8d %10d %10d %10d\n",
+			item.Name,
+			item.Files,
+			item.Lines,
+			item.CodeLines,
+			item.CommentLines,
+			item.BlankLines,
+			item.Characters,
+			item.Functions,
+			item.Classes,
+			item.Test.CodeLines,
+			item.CodeLines-item.Test.CodeLines)
+	}
+
+	totals := report.Totals()
+
+	fmt.Fprintln(w, strings.Repeat("─", 142))
+	fmt.Fprintf(w, "%-15s %8d %12d %12d %12d %8d %12d %8d %10d %10d %10d\n",
+		"TOTAL",
+		totals.Files,
+		totals.Lines,
+		totals.CodeLines,
+		totals.CommentLines,
+		totals.BlankLines,
+		totals.Characters,
+		totals.Functions,
+		totals.Classes,
+		totals.Test.CodeLines,
+		totals.CodeLines-totals.Test.CodeLines)
+
+	if goStats := report.Languages["Go"]; goStats != nil && goStats.Go != nil {
+		renderGo(w, goStats.Go)
+	}
+	if totals.Generated.Files > 0 {
+		renderGenerated(w, report)
+	}
+	if opts.ByDirectory {
+		renderDirectories(w, report)
+	}
+
+	if opts.TopFiles > 0 {
+		renderTopFiles(w, report, opts.TopFiles)
+		if len(report.DuplicateFiles) > 0 {
+			renderDuplicateFiles(w, report, opts.TopFiles)
+		}
+	}
+	if opts.TopNested > 0 {
+		renderNesting(w, report, opts.TopNested)
+	}
+	if opts.TopComplex > 0 {
+		renderComplexity(w, report, opts.TopComplex)
+	}
+	if opts.TopLong > 0 {
+		renderFunctionLengths(w, report, opts.TopLong)
+	}
+	if report.Clones != nil && opts.TopClones > 0 {
+		renderClones(w, report, opts.TopClones)
+	}
+
+	// Show summary
+	fmt.Fprintf(w, "\n Summary:\n")
+	fmt.Fprintf(w, "   Total Size: %s\n", FormatBytes(totals.Size))
+	fmt.Fprintf(w, "   Code Ratio: %.1f%%\n", Percent(totals.CodeLines, totals.Lines))
+	if totals.Length.Functions > 0 {
+		fmt.Fprintf(w, "   Avg Lines/Function: %.1f\n", totals.Length.Avg)
+	}
+	if totals.Test.CodeLines > 0 {
+		fmt.Fprintf(w, "   Test Ratio: %.2f test lines per production line (%d test files)\n",
+			totals.TestRatio(), totals.Test.Files)
+	}
+	if totals.Maintainability != 0 {
+		fmt.Fprintf(w, "   Maintainability Index: %.1f\n", totals.Maintainability)
+	}
+	if totals.Generated.Files > 0 {
+		fmt.Fprintf(w, "   Generated Code: %.1f%% (%d files)\n",
+			Percent(totals.Generated.CodeLines, totals.CodeLines), totals.Generated.Files)
+	}
+	if groups := report.DuplicateFiles; len(groups) > 0 {
+		copies, redundant := 0, int64(0)
+		for _, group := range groups {
+			copies += len(group.Paths) - 1
+			redundant += group.Redundant()
+		}
+		counted := "counted"
+		if report.Deduplicated {
+			counted = "counted once"
+		}
+		fmt.Fprintf(w, "   Identical Files: %d groups, %d extra copies (%s, %s)\n",
+			len(groups), copies, FormatBytes(redundant), counted)
+	}
+	if totals.Duplication != nil {
+		fmt.Fprintf(w, "   Duplicated Code: %.1f%%\n", totals.Duplication.Percent)
+	}
+
+	if report.QualityGates != nil {
+		renderGates(w, report.QualityGates)
+	}
+
+	fmt.Fprintf(w, "\n %s\n", color.BlueString("https://github.com/XanaOG/Walker"))
+	fmt.Fprintf(w, "   %s\n", color.New(color.FgHiBlack).Sprint("Please respect the original author"))
+}
+
+func renderGo(w io.Writer, g *GoStats) {
+	fmt.Fprintf(w, "\n Go:\n")
+	fmt.Fprintf(w, "   %d packages, %d functions, %d methods, %d types (%d structs, %d interfaces, %d aliases)\n",
+		g.Packages, g.Functions, g.Methods, g.Types, g.Structs, g.Interfaces, g.Aliases)
+	fmt.Fprintf(w, "   %d exported, %d unexported, %.1f%% of exported identifiers documented\n",
+		g.Exported, g.Unexported, g.DocCoverage())
+	if g.Tests+g.Benchmarks+g.Fuzz+g.Examples > 0 {
+		fmt.Fprintf(w, "   %d tests, %d benchmarks, %d fuzz targets, %d examples\n",
+			g.Tests, g.Benchmarks, g.Fuzz, g.Examples)
+	}
+}
+
+// renderGenerated splits each language's code lines into generated and
+// hand-written.
+func renderGenerated(w io.Writer, report *Report) {
+	fmt.Fprintf(w, "\n Generated Code:\n")
+	fmt.Fprintf(w, "   %-15s %8s %12s %14s %8s\n", "LANGUAGE", "FILES", "GENERATED", "HAND-WRITTEN", "%")
+	for _, item := range report.SortedLanguages() {
+		if item.Generated.Files == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "   %-15s %8d %12d %14d %7.1f%%\n",
+			item.Name,
+			item.Generated.Files,
+			item.Generated.CodeLines,
+			item.CodeLines-item.Generated.CodeLines,
+			Percent(item.Generated.CodeLines, item.CodeLines))
+	}
+}
+
+// renderDirectories lists each directory's counts, largest first.
+func renderDirectories(w io.Writer, report *Report) {
+	fmt.Fprintf(w, "\n Directories:\n")
+	fmt.Fprintf(w, "   %-40s %8s %10s %10s %10s %10s\n", "DIRECTORY", "FILES", "LINES", "CODE", "TEST", "PROD")
+	for _, dir := range report.Directories() {
+		fmt.Fprintf(w, "   %-40s %8d %10d %10d %10d %10d\n",
+			TruncateString(dir.Path, 40),
+			dir.Files,
+			dir.Lines,
+			dir.CodeLines,
+			dir.TestCodeLines,
+			dir.ProductionCodeLines)
+	}
+}
+
+func renderTopFiles(w io.Writer, report *Report, topN int) {
+	fmt.Fprintf(w, "\n Top %d Files by Lines:\n", topN)
+
+	for i, file := range report.TopFiles(topN) {
+		fmt.Fprintf(w, "%2d. %-55s %10d lines %12d chars\n",
+			i+1,
+			TruncateString(file.Path, 55),
+			file.Lines,
+			file.Characters)
+	}
+}
+
+func renderDuplicateFiles(w io.Writer, report *Report, topN int) {
+	groups := report.DuplicateFiles
+	if len(groups) > topN {
+		groups = groups[:topN]
+	}
+	fmt.Fprintf(w, "\n Identical Files:\n")
+	for i, group := range groups {
+		fmt.Fprintf(w, "%2d. %d copies of %d lines, %s redundant\n",
+			i+1, len(group.Paths), group.Lines, FormatBytes(group.Redundant()))
+		for _, path := range group.Paths {
+			fmt.Fprintf(w, "      %s\n", TruncateString(path, 100))
+		}
+	}
+}
+
+func renderNesting(w io.Writer, report *Report, topN int) {
+	fmt.Fprintf(w, "\n Nesting and Indentation:\n")
+	fmt.Fprintf(w, "   %-15s %8s %8s   %s\n", "LANGUAGE", "MAX", "AVG", "INDENT")
+	for _, item := range report.SortedLanguages() {
+		if item.CodeLines == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "   %-15s %8d %8.1f   %s\n",
+			item.Name,
+			item.Nesting.Max,
+			item.Nesting.Avg,
+			indentLabel(item.Indent))
+	}
+
+	fmt.Fprintf(w, "\n Top %d Deepest Functions:\n", topN)
+	for i, fn := range report.DeepestFunctions(topN) {
+		fmt.Fprintf(w, "%2d. %-30s %-50s %6d levels\n",
+			i+1,
+			TruncateString(functionLabel(fn.Name), 30),
+			TruncateString(fmt.Sprintf("%s:%d", fn.Path, fn.Line), 50),
+			fn.MaxNesting)
+	}
+}
+
+// indentLabel describes an indentation style, such as "spaces (4)".
+func indentLabel(indent IndentStats) string {
+	switch {
+	case indent.Style == "":
+		return "none"
+	case indent.Style == IndentSpaces && indent.Width > 0:
+		return fmt.Sprintf("%s (%d)", indent.Style, indent.Width)
+	case indent.Style == IndentMixed:
+		return fmt.Sprintf("mixed (%d tab, %d space lines)", indent.TabLines, indent.SpaceLines)
+	default:
+		return indent.Style
+	}
+}
+
+func renderComplexity(w io.Writer, report *Report, topN int) {
+	fmt.Fprintf(w, "\n Cyclomatic Complexity:\n")
+	fmt.Fprintf(w, "   %-15s %8s %8s %8s %8s\n", "LANGUAGE", "FUNCS", "MAX", "AVG", "P90")
+	for _, item := range report.SortedLanguages() {
+		if item.Complexity.Functions == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "   %-15s %8d %8d %8.1f %8d\n",
+			item.Name,
+			item.Complexity.Functions,
+			item.Complexity.Max,
+			item.Complexity.Avg,
+			item.Complexity.P90)
+	}
+
+	fmt.Fprintf(w, "\n Top %d Complex Functions:\n", topN)
+	for i, fn := range report.TopComplexFunctions(topN) {
+		fmt.Fprintf(w, "%2d. %-30s %-50s %6d\n",
+			i+1,
+			TruncateString(functionLabel(fn.Name), 30),
+			TruncateString(fmt.Sprintf("%s:%d", fn.Path, fn.Line), 50),
+			fn.Complexity)
+	}
+}
+
+func renderFunctionLengths(w io.Writer, report *Report, topN int) {
+	buckets := report.FunctionLengths()
+	most := 0
+	for _, b := range buckets {
+		if b.Functions > most {
+			most = b.Functions
+		}
+	}
+
+	fmt.Fprintf(w, "\n Function Lengths:\n")
+	for _, b := range buckets {
+		label := fmt.Sprintf("%d+", b.Min)
+		if b.Max > 0 {
+			label = fmt.Sprintf("%d-%d", b.Min, b.Max)
+		}
+		bar := 0
+		if most > 0 {
+			bar = b.Functions * 40 / most
+		}
+		fmt.Fprintf(w, "   %-9s %6d %s\n", label, b.Functions, color.CyanString(strings.Repeat("█", bar)))
+	}
+
+	fmt.Fprintf(w, "\n Top %d Longest Functions:\n", topN)
+	for i, fn := range report.LongestFunctions(topN) {
+		fmt.Fprintf(w, "%2d. %-30s %-50s %6d lines\n",
+			i+1,
+			TruncateString(functionLabel(fn.Name), 30),
+			TruncateString(fmt.Sprintf("%s:%d-%d", fn.Path, fn.Line, fn.EndLine), 50),
+			fn.Lines)
+	}
+}
+
+func renderClones(w io.Writer, report *Report, topN int) {
+	fmt.Fprintf(w, "\n Duplicated Code (%d+ tokens):\n", report.Clones.MinTokens)
+	fmt.Fprintf(w, "   %-15s %12s %12s %8s\n", "LANGUAGE", "CODE", "DUPLICATED", "%")
+	for _, item := range report.SortedLanguages() {
+		if item.Duplication == nil || item.CodeLines == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "   %-15s %12d %12d %7.1f%%\n",
+			item.Name,
+			item.CodeLines,
+			item.Duplication.Lines,
+			item.Duplication.Percent)
+	}
+
+	groups := report.Clones.Groups
+	if len(groups) > topN {
+		groups = groups[:topN]
+	}
+	fmt.Fprintf(w, "\n Top %d Clone Groups:\n", topN)
+	for i, group := range groups {
+		fmt.Fprintf(w, "%2d. %d tokens, %d lines, %d copies\n", i+1, group.Tokens, group.Lines, len(group.Instances))
+		for _, clone := range group.Instances {
+			fmt.Fprintf(w, "      %s\n", TruncateString(fmt.Sprintf("%s:%d-%d", clone.Path, clone.StartLine, clone.EndLine), 100))
+		}
+	}
+}
+
+// functionLabel names a function in listings.
+func functionLabel(name string) string {
+	if name == "" {
+		return "(anonymous)"
+	}
+	return name
+}
+
+func renderGates(w io.Writer, gates *GateResults) {
+	if gates.Passed() {
+		color.New(color.FgGreen).Fprintf(w, "\n Quality Gates: all %d rules passed\n", len(gates.Rules))
+		return
+	}
+
+	color.New(color.FgRed).Fprintf(w, "\n Quality Gates: %d violations\n", len(gates.Violations))
+	for _, v := range gates.Violations {
+		subject := v.Path
+		if subject == "" {
+			subject = v.Language
+		} else if v.StartLine > 0 {
+			subject = fmt.Sprintf("%s:%d", subject, v.StartLine)
+		}
+		fmt.Fprintf(w, "   %-20s %-45s %s\n", v.Rule, TruncateString(subject, 45), v.Message)
+	}
+}
+
+// jsonReport is the document RenderJSON writes and ReadJSON reads back.
+type jsonReport struct {
+	GeneratedAt time.Time                 `json:"generated_at"`
+	Root        string                    `json:"root,omitempty"`
+	Revision    string                    `json:"revision,omitempty"`
+	Incomplete  bool                      `json:"incomplete,omitempty"`
+	Languages   map[string]*LanguageStats `json:"languages"`
+	Summary     map[string]interface{}    `json:"summary"`
+	Gates       *GateResults              `json:"quality_gates,omitempty"`
+	Clones      *CloneReport              `json:"clones,omitempty"`
+	// Deduplicated is set when Languages counts identical files once.
+	Deduplicated   bool             `json:"deduplicated,omitempty"`
+	DuplicateFiles []DuplicateFiles `json:"duplicate_files,omitempty"`
+	// FunctionLengths and Directories are only written; a report read
+	// back recomputes them.
+	FunctionLengths []LengthBucket   `json:"function_lengths"`
+	Directories     []DirectoryStats `json:"directories"`
+}
+
+// RenderJSON writes the report as a single indented JSON document.
+func RenderJSON(w io.Writer, report *Report) error {
+	output := jsonReport{
+		GeneratedAt: report.GeneratedAt,
+		Root:        report.Root,
+		Revision:    report.Revision,
+		Incomplete:  report.Incomplete,
+		Languages:   report.Languages,
+		Summary:     summary(report.Totals()),
+		Gates:       report.QualityGates,
+		Clones:      report.Clones,
+
+		Deduplicated:   report.Deduplicated,
+		DuplicateFiles: report.DuplicateFiles,
+
+		FunctionLengths: report.FunctionLengths(),
+		Directories:     report.Directories(),
+	}
+
+	jsonData, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// ReadJSON loads a report saved by RenderJSON, so earlier runs can be
+// compared without analyzing the tree again.
+func ReadJSON(r io.Reader) (*Report, error) {
+	var input jsonReport
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return nil, err
+	}
+	if input.Languages == nil {
+		return nil, fmt.Errorf("not a walker JSON report: no languages")
+	}
+	return &Report{
+		GeneratedAt:  input.GeneratedAt,
+		Root:         input.Root,
+		Revision:     input.Revision,
+		Incomplete:   input.Incomplete,
+		Languages:    input.Languages,
+		QualityGates: input.Gates,
+		Clones:       input.Clones,
+
+		Deduplicated:   input.Deduplicated,
+		DuplicateFiles: input.DuplicateFiles,
+	}, nil
+}
@@ -0,0 +1,323 @@
+package walker
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Rule identifiers, as reported in Violation.Rule. RuleDescriptions
+// documents each one.
+const (
+	RuleMaxFileLines      = "max-file-lines"
+	RuleMaxFunctions      = "max-functions"
+	RuleMaxFunctionLines  = "max-function-lines"
+	RuleMaxComplexity     = "max-complexity"
+	RuleMinCommentRatio   = "min-comment-ratio"
+	RuleMaxGrowth         = "max-growth"
+	RuleForbiddenLanguage = "forbidden-language"
+)
+
+// RuleDescription documents a rule for tools that show rule metadata.
+type RuleDescription struct {
+	ID          string
+	Name        string
+	Description string
+	Help        string
+}
+
+// RuleDescriptions returns the documentation for every rule, in the order
+// they are listed in Rules.
+func RuleDescriptions() []RuleDescription {
+	return []RuleDescription{
+		{
+			ID:          RuleMaxFileLines,
+			Name:        "MaxFileLines",
+			Description: "File is longer than the configured maximum number of lines.",
+			Help:        "Split the file into smaller, focused files, or raise max_file_lines.",
+		},
+		{
+			ID:          RuleMaxFunctions,
+			Name:        "MaxFunctions",
+			Description: "File declares more functions than the configured maximum.",
+			Help:        "Move related functions into their own file, or raise max_functions.",
+		},
+		{
+			ID:          RuleMaxFunctionLines,
+			Name:        "MaxFunctionLines",
+			Description: "Function is longer than the configured maximum number of lines.",
+			Help:        "Extract parts of the function into helpers, or raise max_function_lines.",
+		},
+		{
+			ID:          RuleMaxComplexity,
+			Name:        "MaxComplexity",
+			Description: "Function's cyclomatic complexity is above the configured maximum.",
+			Help:        "Split the function or simplify its branching, or raise max_complexity.",
+		},
+		{
+			ID:          RuleMinCommentRatio,
+			Name:        "MinCommentRatio",
+			Description: "A language's share of comment lines is below the configured minimum.",
+			Help:        "Document the code, or lower min_comment_ratio for this language with comment_ratio_by_language.",
+		},
+		{
+			ID:          RuleMaxGrowth,
+			Name:        "MaxGrowth",
+			Description: "Code lines grew by more than the allowed percentage over the baseline report.",
+			Help:        "Review the change for unexpected additions, or refresh the baseline report.",
+		},
+		{
+			ID:          RuleForbiddenLanguage,
+			Name:        "ForbiddenLanguage",
+			Description: "A file in a language that is not allowed in its directory.",
+			Help:        "Move or rewrite the file, or update the forbidden list in the rules section.",
+		},
+	}
+}
+
+// Rules are quality gates checked against a report. Zero values disable a
+// rule.
+type Rules struct {
+	// MaxFileLines is the most lines any one file may have.
+	MaxFileLines int
+	// MaxFunctions is the most functions any one file may declare.
+	MaxFunctions int
+	// MaxFunctionLines is the most lines any one function may span.
+	MaxFunctionLines int
+	// MaxComplexity is the highest cyclomatic complexity any one function
+	// may have.
+	MaxComplexity int
+	// MinCommentRatio is the lowest percentage of comment lines among
+	// code and comment lines, checked per language. Languages without
+	// CommentPatterns, such as Markdown or JSON, are only checked when
+	// named in CommentRatioByLanguage.
+	MinCommentRatio float64
+	// CommentRatioByLanguage overrides MinCommentRatio for the named
+	// languages.
+	CommentRatioByLanguage map[string]float64
+	// Registry looks up which languages have comments. Nil means
+	// DefaultRegistry.
+	Registry *Registry
+	// MaxGrowth is the largest allowed increase in code lines over
+	// Baseline, in percent.
+	MaxGrowth float64
+	Baseline  *Report
+	// Forbidden lists languages that must not appear under a directory.
+	Forbidden []ForbiddenLanguages
+}
+
+// ForbiddenLanguages bans Languages from files under Path, a directory
+// relative to the report root. An empty Path is the whole tree.
+type ForbiddenLanguages struct {
+	Path      string
+	Languages []string
+}
+
+// Violation is one failed rule.
+type Violation struct {
+	Rule string `json:"rule"`
+	// Path is the offending file or directory, empty when the rule is
+	// about the whole tree.
+	Path     string `json:"path,omitempty"`
+	Language string `json:"language,omitempty"`
+	// StartLine and EndLine narrow a file violation to a range of lines:
+	// the offending function for function rules, or the lines past the
+	// limit for max-file-lines. Zero means the whole file.
+	StartLine int     `json:"start_line,omitempty"`
+	EndLine   int     `json:"end_line,omitempty"`
+	Message   string  `json:"message"`
+	Actual    float64 `json:"actual"`
+	Limit     float64 `json:"limit"`
+}
+
+// GateResults is the outcome of CheckRules.
+type GateResults struct {
+	// Rules lists the identifiers of the rules that were enabled.
+	Rules      []string    `json:"rules"`
+	Violations []Violation `json:"violations"`
+}
+
+// Passed reports whether every rule held.
+func (g *GateResults) Passed() bool {
+	return len(g.Violations) == 0
+}
+
+// Enabled reports whether any rule is set.
+func (r Rules) Enabled() bool {
+	return len(r.enabled()) > 0
+}
+
+func (r Rules) enabled() []string {
+	var ids []string
+	if r.MaxFileLines > 0 {
+		ids = append(ids, RuleMaxFileLines)
+	}
+	if r.MaxFunctions > 0 {
+		ids = append(ids, RuleMaxFunctions)
+	}
+	if r.MaxFunctionLines > 0 {
+		ids = append(ids, RuleMaxFunctionLines)
+	}
+	if r.MaxComplexity > 0 {
+		ids = append(ids, RuleMaxComplexity)
+	}
+	if r.MinCommentRatio > 0 || len(r.CommentRatioByLanguage) > 0 {
+		ids = append(ids, RuleMinCommentRatio)
+	}
+	if r.MaxGrowth > 0 && r.Baseline != nil {
+		ids = append(ids, RuleMaxGrowth)
+	}
+	if len(r.Forbidden) > 0 {
+		ids = append(ids, RuleForbiddenLanguage)
+	}
+	return ids
+}
+
+// CheckRules evaluates rules against report. Violations are ordered by
+// rule, then path.
+func CheckRules(report *Report, rules Rules) *GateResults {
+	results := &GateResults{Rules: rules.enabled(), Violations: []Violation{}}
+	add := func(v Violation) {
+		results.Violations = append(results.Violations, v)
+	}
+
+	for _, file := range report.Files() {
+		if rules.MaxFileLines > 0 && file.Lines > rules.MaxFileLines {
+			// Point at the lines past the limit.
+			add(Violation{
+				Rule:      RuleMaxFileLines,
+				Path:      file.Path,
+				Language:  file.Language,
+				StartLine: rules.MaxFileLines + 1,
+				EndLine:   file.Lines,
+				Message:   fmt.Sprintf("%d lines exceeds the limit of %d", file.Lines, rules.MaxFileLines),
+				Actual:    float64(file.Lines),
+				Limit:     float64(rules.MaxFileLines),
+			})
+		}
+		if rules.MaxFunctions > 0 && file.Functions > rules.MaxFunctions {
+			add(Violation{
+				Rule:     RuleMaxFunctions,
+				Path:     file.Path,
+				Language: file.Language,
+				Message:  fmt.Sprintf("%d functions exceeds the limit of %d", file.Functions, rules.MaxFunctions),
+				Actual:   float64(file.Functions),
+				Limit:    float64(rules.MaxFunctions),
+			})
+		}
+		for _, fn := range file.FunctionStats {
+			if rules.MaxFunctionLines > 0 && fn.Lines > rules.MaxFunctionLines {
+				add(Violation{
+					Rule:      RuleMaxFunctionLines,
+					Path:      file.Path,
+					Language:  file.Language,
+					StartLine: fn.Line,
+					EndLine:   fn.EndLine,
+					Message:   fmt.Sprintf("%s is %d lines, more than the limit of %d", fn.Name, fn.Lines, rules.MaxFunctionLines),
+					Actual:    float64(fn.Lines),
+					Limit:     float64(rules.MaxFunctionLines),
+				})
+			}
+			if rules.MaxComplexity > 0 && fn.Complexity > rules.MaxComplexity {
+				add(Violation{
+					Rule:      RuleMaxComplexity,
+					Path:      file.Path,
+					Language:  file.Language,
+					StartLine: fn.Line,
+					EndLine:   fn.EndLine,
+					Message:   fmt.Sprintf("%s has complexity %d, more than the limit of %d", fn.Name, fn.Complexity, rules.MaxComplexity),
+					Actual:    float64(fn.Complexity),
+					Limit:     float64(rules.MaxComplexity),
+				})
+			}
+		}
+		for _, f := range rules.Forbidden {
+			if !underDir(relativePath(report.Root, file.Path), f.Path) || !containsFold(f.Languages, file.Language) {
+				continue
+			}
+			where := "this tree"
+			if f.Path != "" {
+				where = f.Path
+			}
+			add(Violation{
+				Rule:     RuleForbiddenLanguage,
+				Path:     file.Path,
+				Language: file.Language,
+				Message:  fmt.Sprintf("%s is not allowed in %s", file.Language, where),
+			})
+		}
+	}
+
+	registry := rules.Registry
+	if registry == nil {
+		registry = DefaultRegistry()
+	}
+	for _, item := range report.SortedLanguages() {
+		limit, ok := rules.CommentRatioByLanguage[item.Name]
+		if !ok {
+			if langConfig, found := registry.Lookup(item.Name); found && len(langConfig.CommentPatterns) == 0 {
+				continue
+			}
+			limit = rules.MinCommentRatio
+		}
+		if limit <= 0 {
+			continue
+		}
+		ratio := Percent(item.CommentLines, item.CodeLines+item.CommentLines)
+		if ratio < limit {
+			add(Violation{
+				Rule:     RuleMinCommentRatio,
+				Language: item.Name,
+				Message:  fmt.Sprintf("%s comment ratio %.1f%% is below %.1f%%", item.Name, ratio, limit),
+				Actual:   ratio,
+				Limit:    limit,
+			})
+		}
+	}
+
+	if rules.MaxGrowth > 0 && rules.Baseline != nil {
+		before := rules.Baseline.Totals().CodeLines
+		after := report.Totals().CodeLines
+		if before > 0 {
+			growth := float64(after-before) / float64(before) * 100
+			if growth > rules.MaxGrowth {
+				add(Violation{
+					Rule:    RuleMaxGrowth,
+					Message: fmt.Sprintf("code lines grew %.1f%% (%d to %d), more than %.1f%%", growth, before, after, rules.MaxGrowth),
+					Actual:  growth,
+					Limit:   rules.MaxGrowth,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(results.Violations, func(i, j int) bool {
+		a, b := results.Violations[i], results.Violations[j]
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Path < b.Path
+	})
+	return results
+}
+
+// underDir reports whether rel, a slash- or OS-separated path relative
+// to the report root, is inside dir.
+func underDir(rel, dir string) bool {
+	dir = strings.Trim(filepath.ToSlash(dir), "/")
+	if dir == "" || dir == "." {
+		return true
+	}
+	rel = filepath.ToSlash(rel)
+	return rel == dir || strings.HasPrefix(rel, dir+"/")
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
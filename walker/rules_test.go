@@ -0,0 +1,55 @@
+package walker
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMinCommentRatio(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.go":   &fstest.MapFile{Data: []byte("package main\n\n// main does nothing.\nfunc main() {\n}\n")},
+		"README.md": &fstest.MapFile{Data: []byte("# Title\n\nSome prose.\n")},
+	}
+	report, err := Analyze(context.Background(), Options{Root: "src", FS: fsys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		rules Rules
+		want  []string
+	}{
+		{
+			name:  "languages without comments are skipped",
+			rules: Rules{MinCommentRatio: 10},
+		},
+		{
+			name:  "commented languages are checked",
+			rules: Rules{MinCommentRatio: 50},
+			want:  []string{"Go"},
+		},
+		{
+			name:  "named languages are checked anyway",
+			rules: Rules{MinCommentRatio: 10, CommentRatioByLanguage: map[string]float64{"Markdown": 5}},
+			want:  []string{"Markdown"},
+		},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, v := range CheckRules(report, tt.rules).Violations {
+			got = append(got, v.Language)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: violations for %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: violations for %v, want %v", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
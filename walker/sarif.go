@@ -0,0 +1,159 @@
+package walker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	// sarifRootID is the base every artifact URI is relative to. Consumers
+	// map it to their checkout.
+	sarifRootID = "%SRCROOT%"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	ShortDescription     sarifMessage      `json:"shortDescription"`
+	FullDescription      sarifMessage      `json:"fullDescription"`
+	Help                 sarifMessage      `json:"help"`
+	DefaultConfiguration map[string]string `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// RenderSARIF writes the report's quality gate violations as a SARIF 2.1.0
+// log, with file paths relative to the report root. Every rule Walker
+// knows is described, so results can refer to them by index. A report
+// without QualityGates yields a log with no results.
+func RenderSARIF(w io.Writer, report *Report) error {
+	descriptions := RuleDescriptions()
+	index := make(map[string]int, len(descriptions))
+	rules := make([]sarifRule, len(descriptions))
+	for i, d := range descriptions {
+		index[d.ID] = i
+		rules[i] = sarifRule{
+			ID:                   d.ID,
+			Name:                 d.Name,
+			ShortDescription:     sarifMessage{d.Description},
+			FullDescription:      sarifMessage{d.Description},
+			Help:                 sarifMessage{d.Help},
+			DefaultConfiguration: map[string]string{"level": "error"},
+		}
+	}
+
+	results := []sarifResult{}
+	if report.QualityGates != nil {
+		for _, v := range report.QualityGates.Violations {
+			result := sarifResult{
+				RuleID:    v.Rule,
+				RuleIndex: index[v.Rule],
+				Level:     "error",
+				Message:   sarifMessage{v.Message},
+				Properties: map[string]interface{}{
+					"actual": v.Actual,
+					"limit":  v.Limit,
+				},
+			}
+			if v.Language != "" {
+				result.Properties["language"] = v.Language
+			}
+			if v.Path != "" {
+				location := sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{
+						URI:       sarifURI(relativePath(report.Root, v.Path)),
+						URIBaseID: sarifRootID,
+					},
+				}
+				if v.StartLine > 0 {
+					location.Region = &sarifRegion{StartLine: v.StartLine, EndLine: v.EndLine}
+				}
+				result.Locations = []sarifLocation{{location}}
+			}
+			results = append(results, result)
+		}
+	}
+
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "Walker",
+				InformationURI: "https://github.com/XanaOG/Walker",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+
+	jsonData, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(jsonData))
+	return err
+}
+
+// sarifURI turns a path relative to the analyzed root into a relative URI
+// reference, percent-encoding spaces, "#", "%" and the like. A path inside
+// an archive keeps its "!/" separator, encoded as "%21/".
+func sarifURI(path string) string {
+	return (&url.URL{Path: filepath.ToSlash(path)}).EscapedPath()
+}
@@ -0,0 +1,45 @@
+package walker
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestSARIFLocationURIs(t *testing.T) {
+	tests := []struct {
+		root string
+		path string
+		want string
+	}{
+		{"src", "src/my dir/a b.go", "my%20dir/a%20b.go"},
+		{"src", "src/100%/#1.go", "100%25/%231.go"},
+		{"src", "src/vendor/lib.zip!/in side.go", "vendor/lib.zip%21/in%20side.go"},
+		{"lib.zip", "lib.zip!/pkg/a b.go", "pkg/a%20b.go"},
+	}
+	for _, tt := range tests {
+		report := &Report{
+			Root:      tt.root,
+			Languages: map[string]*LanguageStats{},
+			QualityGates: &GateResults{
+				Rules:      []string{RuleMaxFileLines},
+				Violations: []Violation{{Rule: RuleMaxFileLines, Path: tt.path, Message: "too long"}},
+			},
+		}
+		var buf bytes.Buffer
+		if err := RenderSARIF(&buf, report); err != nil {
+			t.Fatal(err)
+		}
+		var log sarifLog
+		if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+			t.Fatal(err)
+		}
+		results := log.Runs[0].Results
+		if len(results) != 1 || len(results[0].Locations) != 1 {
+			t.Fatalf("%s: results = %+v", tt.path, results)
+		}
+		if got := results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI; got != tt.want {
+			t.Errorf("%s: uri %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
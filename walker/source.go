@@ -0,0 +1,309 @@
+package walker
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// archiveSeparator joins an archive's path to the path of an entry inside
+// it, as in release.zip!/src/main.go.
+const archiveSeparator = "!/"
+
+// maxBufferedFile is the largest file read into memory from an archive
+// that can't be read in place: an entry of a compressed tarball, or an
+// archive nested in the tree. Larger ones are skipped.
+const maxBufferedFile = 32 << 20
+
+// source is a tree of files to analyze: a directory on disk, an archive,
+// or any other fs.FS. display turns an fs path into the path reported in
+// FileStats.
+type source struct {
+	fsys    fs.FS
+	display func(name string) string
+	close   func() error
+	// attrs, when set, are the .gitattributes of a git revision; attrs
+	// paths are fs paths prefixed with attrPrefix.
+	attrs      *gitAttributes
+	attrPrefix string
+	revision   string
+}
+
+// fileRef identifies one file within a source.
+type fileRef struct {
+	fsys    fs.FS
+	name    string
+	display string
+	// language overrides detection when set, from linguist-language.
+	language string
+	// object is the git blob hash when the file comes from a revision.
+	object string
+}
+
+// IsArchive reports whether path names an archive Analyze can read
+// without extracting it: .zip, .jar, .war, .tar, .tar.gz or .tgz.
+func IsArchive(path string) bool {
+	return archiveKind(path) != ""
+}
+
+func archiveKind(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".zip"), strings.HasSuffix(lower, ".jar"), strings.HasSuffix(lower, ".war"):
+		return "zip"
+	}
+	return ""
+}
+
+// openSource picks how to read opts.Root: a git revision when opts.Rev is
+// set, opts.FS when given, an archive when Root is one, and the directory
+// otherwise.
+func openSource(ctx context.Context, opts Options) (source, error) {
+	root := opts.Root
+	if opts.Rev != "" {
+		rev, err := openGitRevision(ctx, root, opts.Rev)
+		if err != nil {
+			return source{}, err
+		}
+		return source{
+			fsys:       rev.fsys,
+			display:    joinDisplay(root),
+			close:      rev.Close,
+			attrs:      rev.attrs,
+			attrPrefix: rev.prefix,
+			revision:   rev.commit,
+		}, nil
+	}
+	if opts.FS != nil {
+		return source{fsys: opts.FS, display: joinDisplay(root), close: noClose}, nil
+	}
+
+	info, err := os.Stat(root)
+	if err == nil && !info.IsDir() && IsArchive(root) {
+		file, err := os.Open(root)
+		if err != nil {
+			return source{}, err
+		}
+		fsys, err := openArchive(root, file, info.Size())
+		if err != nil {
+			file.Close()
+			return source{}, err
+		}
+		return source{fsys: fsys, display: archiveDisplay(root), close: file.Close}, nil
+	}
+
+	return source{fsys: os.DirFS(root), display: joinDisplay(root), close: noClose}, nil
+}
+
+func noClose() error { return nil }
+
+func joinDisplay(root string) func(string) string {
+	return func(name string) string {
+		if name == "." {
+			return root
+		}
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+}
+
+func archiveDisplay(archive string) func(string) string {
+	return func(name string) string {
+		return archive + archiveSeparator + name
+	}
+}
+
+// openArchive reads the archive in r. Zip archives and plain tarballs are
+// read in place; compressed tarballs are loaded into memory.
+func openArchive(name string, r io.ReaderAt, size int64) (fs.FS, error) {
+	switch archiveKind(name) {
+	case "zip":
+		return zip.NewReader(r, size)
+	case "tar":
+		return readTar(io.NewSectionReader(r, 0, size))
+	case "tgz":
+		gz, err := gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		return readTar(gz)
+	}
+	return nil, errors.New("not an archive")
+}
+
+// walkSource calls visit for every regular file in src. With nested set,
+// archives found inside src are opened and walked as if they were
+// directories.
+func walkSource(ctx context.Context, src source, nested bool, visit func(fileRef) error) error {
+	return fs.WalkDir(src.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil || d.IsDir() {
+			return nil
+		}
+
+		display := src.display(name)
+		if nested && IsArchive(name) {
+			inner, err := openNestedArchive(src.fsys, name)
+			if err != nil {
+				return nil
+			}
+			return walkSource(ctx, source{fsys: inner, display: archiveDisplay(display)}, nested, visit)
+		}
+
+		ref := fileRef{fsys: src.fsys, name: name, display: display}
+		if tree, ok := src.fsys.(*treeFS); ok {
+			ref.object = tree.files[name].object
+		}
+		if src.attrs != nil {
+			if src.attrs.excluded(src.attrPrefix + name) {
+				return nil
+			}
+			ref.language = src.attrs.language(src.attrPrefix + name)
+		}
+		return visit(ref)
+	})
+}
+
+// openNestedArchive loads an archive found inside the tree into memory,
+// since the file it came from may be closed before the workers are done
+// with its contents. Archives over maxBufferedFile are left unopened.
+func openNestedArchive(fsys fs.FS, name string) (fs.FS, error) {
+	info, err := fs.Stat(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > maxBufferedFile {
+		return nil, fmt.Errorf("%s: %d bytes is too large to open in memory", name, info.Size())
+	}
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return openArchive(name, bytes.NewReader(data), int64(len(data)))
+}
+
+// readTar indexes a tarball's regular files. A plain tarball, passed as
+// an *io.SectionReader, is read in place: each file records where its
+// contents start. Any other reader, such as a gzip stream, can't be
+// seeked, so its files are held in memory, skipping those over
+// maxBufferedFile.
+func readTar(r io.Reader) (*treeFS, error) {
+	t := newTreeFS()
+	section, inPlace := r.(*io.SectionReader)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if name == "." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		f := &treeFile{
+			name:    name,
+			size:    hdr.Size,
+			mode:    hdr.FileInfo().Mode(),
+			modTime: hdr.ModTime,
+		}
+		if inPlace && !isSparse(hdr) {
+			// tar.Reader has read the header and nothing more, so the
+			// contents start here.
+			if f.offset, err = section.Seek(0, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			f.at = section
+		} else {
+			if hdr.Size > maxBufferedFile {
+				continue
+			}
+			if f.data, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+			f.size = int64(len(f.data))
+		}
+		t.add(f)
+	}
+	t.sort()
+	return t, nil
+}
+
+// isSparse reports whether hdr describes a PAX sparse file, whose
+// contents aren't stored in one piece and must be read through tar.Reader.
+func isSparse(hdr *tar.Header) bool {
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// detect resolves ref's language, preferring a linguist-language override
+// when it names a registered language.
+func (ref fileRef) detect(registry *Registry) (string, bool) {
+	if ref.language != "" {
+		for _, name := range registry.Names() {
+			if strings.EqualFold(name, ref.language) {
+				return name, true
+			}
+		}
+	}
+	return registry.Detect(ref.display)
+}
+
+// analyzeRef analyzes one file from a source with analyzer, hashing its
+// content and checking whether it was generated on the way. When tokens
+// is set the content is also tokenized for the clone index. Unreadable
+// files yield zero counts, as in AnalyzeFile.
+func analyzeRef(ref fileRef, langConfig LanguageConfig, analyzer Analyzer, generated *GeneratedRules, tokens *cloneScanner) FileStats {
+	file, err := ref.fsys.Open(ref.name)
+	if err != nil {
+		return FileStats{Path: ref.display}
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	header := &headerWriter{max: generated.headerBytes()}
+	writers := []io.Writer{hash, header}
+	if tokens != nil {
+		writers = append(writers, tokens)
+	}
+	content := io.MultiWriter(writers...)
+	stats, _ := analyzer.Analyze(io.TeeReader(file, content), ref.display, langConfig)
+	// Hash whatever the analyzer left unread, such as the rest of a file
+	// with a line too long to scan.
+	if _, err := io.Copy(content, file); err == nil {
+		stats.Hash = hex.EncodeToString(hash.Sum(nil))
+	}
+	if info, err := file.Stat(); err == nil {
+		stats.Size = info.Size()
+	}
+	stats.Generated = generated.isGenerated(stats, header.buf, langConfig)
+	return stats
+}
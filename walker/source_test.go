@@ -0,0 +1,78 @@
+package walker
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTar writes files to a tarball, gzipped when compress is set.
+func writeTar(t *testing.T, path string, files map[string]string, compress bool) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if compress {
+		var gz bytes.Buffer
+		zw := gzip.NewWriter(&gz)
+		zw.Write(data)
+		zw.Close()
+		data = gz.Bytes()
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAnalyzeTarballs(t *testing.T) {
+	files := map[string]string{
+		"main.go":      "package main\n\n// main runs.\nfunc main() {\n}\n",
+		"lib/util.py":  "def f():\n    return 1\n",
+		"empty.go":     "",
+		strings.Repeat("long/", 30) + "deep.go": "package deep\n",
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"src.tar", "src.tar.gz"} {
+		archive := filepath.Join(dir, name)
+		writeTar(t, archive, files, strings.HasSuffix(name, ".gz"))
+
+		report, err := Analyze(context.Background(), Options{Root: archive})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]FileStats)
+		for _, file := range report.Files() {
+			got[file.Path] = file.FileStats
+		}
+		if len(got) != len(files) {
+			t.Fatalf("%s: analyzed %d files, want %d", name, len(got), len(files))
+		}
+		for path, content := range files {
+			stats, ok := got[archive+archiveSeparator+path]
+			if !ok {
+				t.Errorf("%s: %s was not analyzed", name, path)
+				continue
+			}
+			if stats.Size != int64(len(content)) || stats.Lines != strings.Count(content, "\n") {
+				t.Errorf("%s: %s has %d bytes, %d lines; want %d, %d", name, path, stats.Size, stats.Lines, len(content), strings.Count(content, "\n"))
+			}
+		}
+	}
+}
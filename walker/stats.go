@@ -0,0 +1,227 @@
+package walker
+
+import "sort"
+
+// FileStats holds the counts for a single analyzed file.
+type FileStats struct {
+	Path         string
+	Lines        int
+	CodeLines    int
+	CommentLines int
+	BlankLines   int
+	Characters   int
+	Functions    int
+	Classes      int
+	Size         int64
+	// Hash is the SHA-256 of the file's content, set by Analyze.
+	Hash string `json:",omitempty"`
+	// Generated is set by Analyze for files Options.Generated matches.
+	Generated bool
+	// Test is set for test files, and TestCodeLines counts the code lines
+	// that are test code: all of a test file's, or the test items an
+	// analyzer found in another file.
+	Test          bool
+	TestCodeLines int
+	// FunctionStats lists the functions in declaration order.
+	FunctionStats []FunctionStats
+	Complexity    FunctionSummary
+	Length        FunctionSummary
+	Nesting       NestingStats
+	Indent        IndentStats
+	Halstead      HalsteadStats
+	// Maintainability is the classic maintainability index; higher is
+	// easier to maintain.
+	Maintainability float64
+	// Go is set for Go files that parsed.
+	Go *GoStats `json:",omitempty"`
+	// Metrics holds measures an Analyzer adds beyond the fields above.
+	Metrics map[string]float64 `json:",omitempty"`
+
+	tracker functionTracker
+}
+
+// LanguageStats aggregates FileStats for every file of one language.
+type LanguageStats struct {
+	Files        int
+	Lines        int
+	CodeLines    int
+	CommentLines int
+	BlankLines   int
+	Characters   int
+	Functions    int
+	Classes      int
+	Size         int64
+	FileStats    []FileStats
+	// Generated counts the part of the totals above that is generated,
+	// and Test the part that is test code.
+	Generated GeneratedStats
+	Test      TestStats
+	// Complexity and Length summarize every function in FileStats.
+	Complexity FunctionSummary
+	Length     FunctionSummary
+	Nesting    NestingStats
+	Indent     IndentStats
+	Halstead   HalsteadStats
+	// Maintainability averages the files' index, weighted by code lines.
+	Maintainability float64
+	// Duplication is set when Analyze looked for clones.
+	Duplication *DuplicationStats `json:",omitempty"`
+	Go          *GoStats          `json:",omitempty"`
+	// Metrics sums each file's Metrics.
+	Metrics map[string]float64 `json:",omitempty"`
+}
+
+// add folds a file's counts into the language totals.
+func (s *LanguageStats) add(file FileStats) {
+	s.Files++
+	s.Lines += file.Lines
+	s.CodeLines += file.CodeLines
+	s.CommentLines += file.CommentLines
+	s.BlankLines += file.BlankLines
+	s.Characters += file.Characters
+	s.Functions += file.Functions
+	s.Classes += file.Classes
+	s.Size += file.Size
+	if file.Generated {
+		s.Generated.add(file)
+	}
+	if file.Test {
+		s.Test.Files++
+	}
+	s.Test.CodeLines += file.TestCodeLines
+	if file.Nesting.Max > s.Nesting.Max {
+		s.Nesting.Max = file.Nesting.Max
+	}
+	s.Indent.TabLines += file.Indent.TabLines
+	s.Indent.SpaceLines += file.Indent.SpaceLines
+	s.addHalstead(file)
+	if file.Go != nil {
+		if s.Go == nil {
+			s.Go = &GoStats{}
+		}
+		s.Go.add(file.Go)
+	}
+	for name, value := range file.Metrics {
+		if s.Metrics == nil {
+			s.Metrics = make(map[string]float64)
+		}
+		s.Metrics[name] += value
+	}
+}
+
+// NamedStats pairs a language name with its statistics.
+type NamedStats struct {
+	Name string
+	*LanguageStats
+}
+
+// FileResult pairs a file's statistics with the language it was analyzed as.
+type FileResult struct {
+	Language string
+	FileStats
+}
+
+// Totals sums every language's counters and summarizes every function.
+// FileStats is left empty.
+func (r *Report) Totals() LanguageStats {
+	var totals LanguageStats
+	for _, langStats := range r.Languages {
+		totals.Files += langStats.Files
+		totals.Lines += langStats.Lines
+		totals.CodeLines += langStats.CodeLines
+		totals.CommentLines += langStats.CommentLines
+		totals.BlankLines += langStats.BlankLines
+		totals.Characters += langStats.Characters
+		totals.Functions += langStats.Functions
+		totals.Classes += langStats.Classes
+		totals.Size += langStats.Size
+		totals.Generated.Files += langStats.Generated.Files
+		totals.Generated.Lines += langStats.Generated.Lines
+		totals.Generated.CodeLines += langStats.Generated.CodeLines
+		totals.Test.Files += langStats.Test.Files
+		totals.Test.CodeLines += langStats.Test.CodeLines
+	}
+	var files []FileStats
+	for _, langStats := range r.Languages {
+		files = append(files, langStats.FileStats...)
+		if langStats.Duplication != nil {
+			if totals.Duplication == nil {
+				totals.Duplication = &DuplicationStats{}
+			}
+			totals.Duplication.Lines += langStats.Duplication.Lines
+		}
+	}
+	if totals.Duplication != nil {
+		totals.Duplication.Percent = Percent(totals.Duplication.Lines, totals.CodeLines)
+	}
+	totals.Complexity, totals.Length = summarizeFunctions(files)
+	totals.Maintainability = averageMaintainability(files)
+	return totals
+}
+
+// SortedLanguages orders languages by total lines, largest first.
+func (r *Report) SortedLanguages() []NamedStats {
+	var sorted []NamedStats
+	for lang, langStats := range r.Languages {
+		sorted = append(sorted, NamedStats{lang, langStats})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Lines != sorted[j].Lines {
+			return sorted[i].Lines > sorted[j].Lines
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// Files returns every analyzed file with its language, in no particular
+// order.
+func (r *Report) Files() []FileResult {
+	var files []FileResult
+	for lang, langStats := range r.Languages {
+		for _, file := range langStats.FileStats {
+			files = append(files, FileResult{lang, file})
+		}
+	}
+	return files
+}
+
+// TopFiles returns the topN files with the most lines.
+func (r *Report) TopFiles(topN int) []FileResult {
+	files := r.Files()
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Lines > files[j].Lines
+	})
+	if len(files) > topN {
+		files = files[:topN]
+	}
+	return files
+}
+
+// summary is the totals block shared by the JSON and NDJSON renderers.
+func summary(totals LanguageStats) map[string]interface{} {
+	values := map[string]interface{}{
+		"total_files":        totals.Files,
+		"total_lines":        totals.Lines,
+		"total_code_lines":   totals.CodeLines,
+		"total_comments":     totals.CommentLines,
+		"total_blank":        totals.BlankLines,
+		"total_chars":        totals.Characters,
+		"total_functions":    totals.Functions,
+		"total_classes":      totals.Classes,
+		"total_size":         totals.Size,
+		"code_ratio":         Percent(totals.CodeLines, totals.Lines),
+		"avg_function_lines": totals.Length.Avg,
+		"maintainability":    totals.Maintainability,
+		"generated_files":    totals.Generated.Files,
+		"generated_code":     totals.Generated.CodeLines,
+		"test_files":         totals.Test.Files,
+		"test_code_lines":    totals.Test.CodeLines,
+		"test_ratio":         totals.TestRatio(),
+	}
+	if totals.Duplication != nil {
+		values["duplicated_lines"] = totals.Duplication.Lines
+		values["duplication"] = totals.Duplication.Percent
+	}
+	return values
+}
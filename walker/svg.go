@@ -0,0 +1,231 @@
+package walker
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// SVGs are generated locally so CI jobs can commit them without reaching
+// out to a badge service. Text widths are estimated rather than measured,
+// which is close enough for the short labels shields-style badges carry.
+const (
+	badgeCharWidth = 7
+	badgePadding   = 10
+	badgeHeight    = 20
+
+	chartBarHeight  = 22
+	chartBarGap     = 6
+	chartLabelWidth = 110
+	chartValueWidth = 80
+	chartBarWidth   = 400
+	chartMargin     = 10
+)
+
+var chartPalette = []string{
+	"#4c71f2", "#e5533d", "#f2b134", "#36a269", "#8e5cd9",
+	"#22a7c4", "#d95c9f", "#7a8b99", "#b5863f", "#5fb336",
+}
+
+// Badge is one shields-style badge. File is the suggested file name.
+type Badge struct {
+	File  string
+	Label string
+	Value string
+	Color string
+}
+
+// Badges returns the lines of code, top language and comment ratio badges
+// for report.
+func Badges(report *Report) []Badge {
+	totals := report.Totals()
+
+	topLanguage := "none"
+	if sorted := report.SortedLanguages(); len(sorted) > 0 {
+		topLanguage = sorted[0].Name
+	}
+
+	commentRatio := Percent(totals.CommentLines, totals.CodeLines+totals.CommentLines)
+	commentColor := "#e05d44"
+	switch {
+	case commentRatio >= 20:
+		commentColor = "#4c1"
+	case commentRatio >= 10:
+		commentColor = "#dfb317"
+	}
+
+	return []Badge{
+		{"lines.svg", "lines of code", FormatCount(totals.CodeLines), "#007ec6"},
+		{"language.svg", "top language", topLanguage, "#007ec6"},
+		{"comments.svg", "comment ratio", fmt.Sprintf("%.1f%%", commentRatio), commentColor},
+	}
+}
+
+// RenderBadge returns b as an SVG document.
+func RenderBadge(b Badge) string {
+	label, value, valueColor := b.Label, b.Value, b.Color
+	labelWidth := len(label)*badgeCharWidth + badgePadding
+	valueWidth := len(value)*badgeCharWidth + badgePadding
+	width := labelWidth + valueWidth
+	label, value = html.EscapeString(label), html.EscapeString(value)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="%s: %s">`+"\n",
+		width, badgeHeight, label, value)
+	fmt.Fprintf(&buf, `  <linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+"\n")
+	fmt.Fprintf(&buf, `  <clipPath id="r"><rect width="%d" height="%d" rx="3" fill="#fff"/></clipPath>`+"\n", width, badgeHeight)
+	fmt.Fprintf(&buf, `  <g clip-path="url(#r)">`+"\n")
+	fmt.Fprintf(&buf, `    <rect width="%d" height="%d" fill="#555"/>`+"\n", labelWidth, badgeHeight)
+	fmt.Fprintf(&buf, `    <rect x="%d" width="%d" height="%d" fill="%s"/>`+"\n", labelWidth, valueWidth, badgeHeight, valueColor)
+	fmt.Fprintf(&buf, `    <rect width="%d" height="%d" fill="url(#s)"/>`+"\n", width, badgeHeight)
+	fmt.Fprintf(&buf, `  </g>`+"\n")
+	fmt.Fprintf(&buf, `  <g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+"\n")
+	fmt.Fprintf(&buf, `    <text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`+"\n",
+		labelWidth/2, label, labelWidth/2, label)
+	fmt.Fprintf(&buf, `    <text x="%d" y="15" fill="#010101" fill-opacity=".3">%s</text><text x="%d" y="14">%s</text>`+"\n",
+		labelWidth+valueWidth/2, value, labelWidth+valueWidth/2, value)
+	fmt.Fprintf(&buf, `  </g>`+"\n")
+	buf.WriteString("</svg>\n")
+	return buf.String()
+}
+
+// RenderChart draws a horizontal bar per language, scaled to the language
+// with the most code lines, and returns it as an SVG document.
+func RenderChart(report *Report) string {
+	sorted := report.SortedLanguages()
+
+	maxCode := 0
+	for _, item := range sorted {
+		if item.CodeLines > maxCode {
+			maxCode = item.CodeLines
+		}
+	}
+
+	width := chartMargin*2 + chartLabelWidth + chartBarWidth + chartValueWidth
+	height := chartMargin*2 + len(sorted)*(chartBarHeight+chartBarGap)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="Code lines per language">`+"\n", width, height)
+	fmt.Fprintf(&b, `  <rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `  <g font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="12" fill="#333">`+"\n")
+	for i, item := range sorted {
+		y := chartMargin + i*(chartBarHeight+chartBarGap)
+		barWidth := 0
+		if maxCode > 0 {
+			barWidth = item.CodeLines * chartBarWidth / maxCode
+		}
+		textY := y + chartBarHeight/2 + 4
+
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="end">%s</text>`+"\n",
+			chartMargin+chartLabelWidth-8, textY, html.EscapeString(item.Name))
+		fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="%d" height="%d" rx="2" fill="%s"/>`+"\n",
+			chartMargin+chartLabelWidth, y, barWidth, chartBarHeight, chartPalette[i%len(chartPalette)])
+		fmt.Fprintf(&b, `    <text x="%d" y="%d">%s</text>`+"\n",
+			chartMargin+chartLabelWidth+barWidth+6, textY, FormatCount(item.CodeLines))
+	}
+	fmt.Fprintf(&b, `  </g>`+"\n")
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// Line chart layout for RenderHistoryChart.
+const (
+	lineChartWidth       = 640
+	lineChartHeight      = 280
+	lineChartAxisWidth   = 60
+	lineChartAxisHeight  = 24
+	lineChartLegendWidth = 140
+	lineChartGridLines   = 4
+	lineChartMaxSeries   = 8
+)
+
+// RenderHistoryChart draws code lines over time, one line per language,
+// and returns it as an SVG document. Only the languages with the most code
+// at the last point are drawn, to keep the chart readable.
+func RenderHistoryChart(points []HistoryPoint) string {
+	var langs []string
+	maxCode := 0
+	if len(points) > 0 {
+		last := points[len(points)-1].Languages
+		for lang := range last {
+			langs = append(langs, lang)
+		}
+		sort.Slice(langs, func(i, j int) bool {
+			if last[langs[i]].CodeLines != last[langs[j]].CodeLines {
+				return last[langs[i]].CodeLines > last[langs[j]].CodeLines
+			}
+			return langs[i] < langs[j]
+		})
+		if len(langs) > lineChartMaxSeries {
+			langs = langs[:lineChartMaxSeries]
+		}
+	}
+	for _, p := range points {
+		for _, lang := range langs {
+			if code := p.Languages[lang].CodeLines; code > maxCode {
+				maxCode = code
+			}
+		}
+	}
+
+	left := chartMargin + lineChartAxisWidth
+	top := chartMargin
+	width := left + lineChartWidth + lineChartLegendWidth + chartMargin
+	height := top + lineChartHeight + lineChartAxisHeight + chartMargin
+
+	x := func(i int) int {
+		if len(points) < 2 {
+			return left
+		}
+		first, last := points[0].Date, points[len(points)-1].Date
+		span := last.Sub(first)
+		if span <= 0 {
+			return left + i*lineChartWidth/(len(points)-1)
+		}
+		return left + int(float64(lineChartWidth)*float64(points[i].Date.Sub(first))/float64(span))
+	}
+	y := func(v int) int {
+		if maxCode == 0 {
+			return top + lineChartHeight
+		}
+		return top + lineChartHeight - v*lineChartHeight/maxCode
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" role="img" aria-label="Code lines per language over time">`+"\n", width, height)
+	fmt.Fprintf(&b, `  <rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `  <g font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11" fill="#333">`+"\n")
+
+	for i := 0; i <= lineChartGridLines; i++ {
+		value := maxCode * i / lineChartGridLines
+		gy := top + lineChartHeight - lineChartHeight*i/lineChartGridLines
+		fmt.Fprintf(&b, `    <line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#e5e5e5"/>`+"\n", left, gy, left+lineChartWidth, gy)
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="end">%s</text>`+"\n", left-6, gy+4, FormatCount(value))
+	}
+
+	if len(points) > 0 {
+		labelY := top + lineChartHeight + 16
+		fmt.Fprintf(&b, `    <text x="%d" y="%d">%s</text>`+"\n", left, labelY, points[0].Date.Format("2006-01-02"))
+		fmt.Fprintf(&b, `    <text x="%d" y="%d" text-anchor="end">%s</text>`+"\n",
+			left+lineChartWidth, labelY, points[len(points)-1].Date.Format("2006-01-02"))
+	}
+
+	for i, lang := range langs {
+		color := chartPalette[i%len(chartPalette)]
+		coords := make([]string, len(points))
+		for j, p := range points {
+			coords[j] = fmt.Sprintf("%d,%d", x(j), y(p.Languages[lang].CodeLines))
+		}
+		fmt.Fprintf(&b, `    <polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(coords, " "), color)
+
+		legendX := left + lineChartWidth + 16
+		legendY := top + i*18
+		fmt.Fprintf(&b, `    <rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", legendX, legendY, color)
+		fmt.Fprintf(&b, `    <text x="%d" y="%d">%s</text>`+"\n", legendX+16, legendY+9, html.EscapeString(lang))
+	}
+
+	fmt.Fprintf(&b, `  </g>`+"\n")
+	b.WriteString("</svg>\n")
+	return b.String()
+}
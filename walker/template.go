@@ -0,0 +1,141 @@
+package walker
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the model templates are executed against. Field names
+// are part of the public contract documented in the README, so rename
+// with care.
+type TemplateData struct {
+	GeneratedAt time.Time
+	Root        string
+	Revision    string
+	Incomplete  bool
+	Languages   []NamedStats
+	Totals      LanguageStats
+	CodeRatio   float64
+	TopFiles    []FileResult
+	Directories []DirectoryStats
+	// QualityGates is nil unless rules were checked.
+	QualityGates *GateResults
+}
+
+// DirectoryStats rolls up the files directly inside one directory.
+// CodeLines splits into TestCodeLines and ProductionCodeLines.
+type DirectoryStats struct {
+	Path         string
+	Files        int
+	Lines        int
+	CodeLines    int
+	CommentLines int
+	BlankLines   int
+	Size         int64
+
+	TestFiles           int
+	TestCodeLines       int
+	ProductionCodeLines int
+}
+
+// TemplateFuncs are available to every template parsed with ParseTemplate.
+var TemplateFuncs = template.FuncMap{
+	"formatBytes":    FormatBytes,
+	"truncateString": TruncateString,
+	"percent":        Percent,
+	"padLeft":        padLeft,
+	"padRight":       padRight,
+	"repeat":         strings.Repeat,
+	"upper":          strings.ToUpper,
+	"lower":          strings.ToLower,
+	"add":            func(a, b int) int { return a + b },
+}
+
+// ParseTemplate parses text with TemplateFuncs available.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(TemplateFuncs).Parse(text)
+}
+
+// RenderTemplate executes tmpl against the report, listing topN files in
+// TopFiles.
+func RenderTemplate(w io.Writer, tmpl *template.Template, report *Report, topN int) error {
+	return tmpl.Execute(w, NewTemplateData(report, topN))
+}
+
+// NewTemplateData builds the template model for report.
+func NewTemplateData(report *Report, topN int) TemplateData {
+	data := TemplateData{
+		GeneratedAt:  report.GeneratedAt,
+		Root:         report.Root,
+		Revision:     report.Revision,
+		Incomplete:   report.Incomplete,
+		Languages:    report.SortedLanguages(),
+		Totals:       report.Totals(),
+		TopFiles:     report.TopFiles(topN),
+		Directories:  report.Directories(),
+		QualityGates: report.QualityGates,
+	}
+	data.CodeRatio = Percent(data.Totals.CodeLines, data.Totals.Lines)
+	return data
+}
+
+// Directories rolls files up by the directory containing them, relative
+// to the report root, largest first.
+func (r *Report) Directories() []DirectoryStats {
+	dirs := make(map[string]*DirectoryStats)
+	for _, file := range r.Files() {
+		dir := filepath.Dir(relativePath(r.Root, file.Path))
+		d := dirs[dir]
+		if d == nil {
+			d = &DirectoryStats{Path: dir}
+			dirs[dir] = d
+		}
+		d.Files++
+		d.Lines += file.Lines
+		d.CodeLines += file.CodeLines
+		d.CommentLines += file.CommentLines
+		d.BlankLines += file.BlankLines
+		d.Size += file.Size
+		if file.Test {
+			d.TestFiles++
+		}
+		d.TestCodeLines += file.TestCodeLines
+		d.ProductionCodeLines += file.CodeLines - file.TestCodeLines
+	}
+
+	var result []DirectoryStats
+	for _, d := range dirs {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Lines > result[j].Lines
+	})
+	return result
+}
+
+// relativePath reports path relative to root, falling back to path itself
+// when the two cannot be related. Paths inside an archive root are taken
+// relative to the archive.
+func relativePath(root, path string) string {
+	if inner := strings.TrimPrefix(path, root+archiveSeparator); inner != path {
+		return inner
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func padLeft(width int, v interface{}) string {
+	return fmt.Sprintf("%*v", width, v)
+}
+
+func padRight(width int, v interface{}) string {
+	return fmt.Sprintf("%-*v", width, v)
+}
@@ -0,0 +1,154 @@
+package walker
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TestRules decide which files are test code. Paths are matched relative
+// to the analyzed root.
+type TestRules struct {
+	// Patterns are matched against each file's base name with
+	// filepath.Match.
+	Patterns []string
+	// Dirs mark every file below a directory with this name, or below
+	// this run of directories, such as "src/it".
+	Dirs []string
+	// Production overrides the rules above and the Rust analyzer: a file
+	// whose base name matches one with filepath.Match, or whose path has
+	// one as whole elements, such as "e2e" or "tools/testgen", is never
+	// test code.
+	Production []string
+}
+
+// DefaultTestRules returns the rules Analyze uses when Options.Tests is
+// nil: the usual test file names of each language's test runner and
+// directories such as tests, __tests__ and spec.
+func DefaultTestRules() TestRules {
+	return TestRules{
+		Patterns: []string{
+			"*_test.go",
+			"test_*.py", "*_test.py", "conftest.py",
+			"*.test.js", "*.spec.js", "*.test.jsx", "*.spec.jsx", "*.test.mjs", "*.spec.mjs",
+			"*.test.ts", "*.spec.ts", "*.test.tsx", "*.spec.tsx",
+			"*Test.java", "*Tests.java", "*Test.kt", "*Tests.kt", "*Test.scala", "*Spec.scala",
+			"*Test.cs", "*Tests.cs", "*Tests.swift", "*Test.php",
+			"*_spec.rb", "*_test.rb", "*_test.exs", "*_test.dart",
+		},
+		Dirs: []string{"test", "tests", "__tests__", "spec"},
+	}
+}
+
+// classify marks stats as test code when name, the file's slash-separated
+// path below the root, matches the rules. A whole test file counts all of
+// its code lines as test code; other files keep any TestCodeLines their
+// analyzer found.
+func (t *TestRules) classify(name string, stats *FileStats) {
+	base := path.Base(name)
+	for _, pattern := range t.Production {
+		if matched, _ := filepath.Match(pattern, base); matched || hasElements(name, pattern) {
+			stats.Test, stats.TestCodeLines = false, 0
+			return
+		}
+	}
+	if t.isTestFile(name, base) {
+		stats.Test, stats.TestCodeLines = true, stats.CodeLines
+	}
+}
+
+func (t *TestRules) isTestFile(name, base string) bool {
+	for _, pattern := range t.Patterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
+	for _, dir := range t.Dirs {
+		if hasElements(path.Dir(name), dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasElements reports whether the slash-separated path name contains the
+// elements of elems as a run, so "test" matches "a/test/b" but not
+// "a/latest/b".
+func hasElements(name, elems string) bool {
+	return strings.Contains("/"+name+"/", "/"+strings.Trim(elems, "/")+"/")
+}
+
+// TestStats counts a language's test code. Its production code is the
+// rest of CodeLines.
+type TestStats struct {
+	Files     int
+	CodeLines int
+}
+
+// TestRatio is lines of test code per line of production code, or 0 when
+// there is no production code.
+func (s *LanguageStats) TestRatio() float64 {
+	production := s.CodeLines - s.Test.CodeLines
+	if production <= 0 {
+		return 0
+	}
+	return float64(s.Test.CodeLines) / float64(production)
+}
+
+// RustAnalyzer counts lines like RegexAnalyzer and also counts the code
+// lines of items marked #[cfg(test)], usually a tests module, as test
+// code.
+type RustAnalyzer struct{}
+
+var (
+	rustTestAttribute = regexp.MustCompile(`^\s*#\[cfg\(test\)\]`)
+	rustAttribute     = regexp.MustCompile(`^\s*#!?\[`)
+	// rustAttributes matches the attributes a line starts with, when
+	// each fits on it.
+	rustAttributes = regexp.MustCompile(`^\s*(#!?\[[^\]]*\]\s*)*`)
+)
+
+// Analyze implements Analyzer.
+func (RustAnalyzer) Analyze(r io.Reader, path string, langConfig LanguageConfig) (FileStats, error) {
+	counter := &countingReader{r: r}
+	stats := FileStats{Path: path}
+	pending, depth := false, 0
+	// item starts the test item on line, which may also hold its
+	// attributes. The item ends on this line unless it opens a body, or
+	// its body's brace is still to come.
+	item := func(line Line) {
+		depth = line.Blocks.Net
+		trimmed := strings.TrimSpace(line.Text)
+		pending = depth == 0 && !strings.HasSuffix(trimmed, ";") && !strings.HasSuffix(trimmed, "}")
+	}
+	err := ScanLines(counter, langConfig, func(line Line) {
+		stats.AddLine(line)
+		if line.Kind != LineCode {
+			return
+		}
+		switch {
+		case depth > 0:
+			// Inside a test item until its braces balance.
+			stats.TestCodeLines++
+			depth += line.Blocks.Net
+		case rustTestAttribute.MatchString(line.Text):
+			stats.TestCodeLines++
+			pending = true
+			if len(rustAttributes.FindString(line.Text)) < len(strings.TrimRight(line.Text, " \t")) {
+				// The item follows its attributes on the same line.
+				item(line)
+			}
+		case pending && rustAttribute.MatchString(line.Text):
+			// More attributes on the same item.
+			stats.TestCodeLines++
+		case pending:
+			stats.TestCodeLines++
+			item(line)
+		}
+	})
+	stats.Size = counter.n
+	stats.Finish()
+	return stats, err
+}
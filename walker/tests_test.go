@@ -0,0 +1,140 @@
+package walker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClassifyTestFiles(t *testing.T) {
+	rules := DefaultTestRules()
+	rules.Production = []string{"e2e", "testdata_gen.go"}
+
+	tests := []struct {
+		name string
+		test bool
+	}{
+		{"walker/walker_test.go", true},
+		{"walker/walker.go", false},
+		{"pkg/test_utils.py", true},
+		{"pkg/conftest.py", true},
+		{"web/app.spec.ts", true},
+		{"web/__tests__/app.js", true},
+		{"src/test/java/AppTest.java", true},
+		{"src/test/java/Helpers.java", true},
+		{"src/main/java/App.java", false},
+		{"tests/fixtures/data.go", true},
+		{"latest/main.go", false},
+		{"contest/main.go", false},
+		{"e2e/login_test.go", false},
+		{"web/e2e/app.spec.ts", false},
+		{"web/e2e-utils/app.spec.ts", true},
+		{"tests/testdata_gen.go", false},
+	}
+	for _, tt := range tests {
+		stats := FileStats{CodeLines: 10}
+		rules.classify(tt.name, &stats)
+		if stats.Test != tt.test {
+			t.Errorf("classify(%q): Test = %v, want %v", tt.name, stats.Test, tt.test)
+		}
+		want := 0
+		if tt.test {
+			want = 10
+		}
+		if stats.TestCodeLines != want {
+			t.Errorf("classify(%q): TestCodeLines = %d, want %d", tt.name, stats.TestCodeLines, want)
+		}
+	}
+}
+
+func TestClassifyKeepsAnalyzerTestLines(t *testing.T) {
+	rules := DefaultTestRules()
+	stats := FileStats{CodeLines: 10, TestCodeLines: 4}
+	rules.classify("src/lib.rs", &stats)
+	if stats.Test || stats.TestCodeLines != 4 {
+		t.Errorf("classify(src/lib.rs) = Test %v, TestCodeLines %d; want false, 4", stats.Test, stats.TestCodeLines)
+	}
+
+	rules.Production = []string{"src"}
+	rules.classify("src/lib.rs", &stats)
+	if stats.TestCodeLines != 0 {
+		t.Errorf("classify with a production override: TestCodeLines = %d, want 0", stats.TestCodeLines)
+	}
+}
+
+func TestRustAnalyzerTestCode(t *testing.T) {
+	langConfig, ok := DefaultRegistry().Lookup("Rust")
+	if !ok {
+		t.Fatal("Rust is not registered")
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   int
+	}{
+		{
+			name: "attribute on its own line",
+			source: `fn main() {}
+
+#[cfg(test)]
+mod tests {
+    #[test]
+    fn works() {
+        assert!(true);
+    }
+}
+`,
+			want: 7,
+		},
+		{
+			name: "attribute on the item's line",
+			source: `fn main() {}
+
+#[cfg(test)] mod tests {
+    #[test]
+    fn works() {
+        assert!(true);
+    }
+}
+`,
+			want: 6,
+		},
+		{
+			name: "brace on the next line",
+			source: `#[cfg(test)]
+mod tests
+{
+    fn works() {}
+}
+fn main() {}
+`,
+			want: 5,
+		},
+		{
+			name: "single-line items",
+			source: `#[cfg(test)]
+use std::fmt;
+#[cfg(test)] #[allow(dead_code)] fn helper() -> u8 { 1 }
+#[cfg(test)]
+#[allow(dead_code)]
+const N: u8 = 1;
+fn main() {}
+`,
+			want: 6,
+		},
+		{
+			name:   "no test code",
+			source: "fn main() {\n    println!(\"test\");\n}\n",
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		stats, err := RustAnalyzer{}.Analyze(strings.NewReader(tt.source), "lib.rs", langConfig)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stats.TestCodeLines != tt.want {
+			t.Errorf("%s: TestCodeLines = %d, want %d", tt.name, stats.TestCodeLines, tt.want)
+		}
+	}
+}
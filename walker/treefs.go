@@ -0,0 +1,158 @@
+package walker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"time"
+)
+
+// treeFS is a read-only fs.FS built from a flat list of files, for
+// sources such as tarballs and git trees that don't come with one. File
+// contents are held in memory, read in place from an archive, or loaded
+// when the file is opened.
+type treeFS struct {
+	files map[string]*treeFile
+	dirs  map[string][]fs.DirEntry
+}
+
+type treeFile struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+	data    []byte
+	// at, when set, holds the contents at offset, as in a tarball read in
+	// place.
+	at     io.ReaderAt
+	offset int64
+	// object is the git blob hash for files read from a revision.
+	object string
+	// load, when set, fetches the contents on each Open instead of data.
+	load func() ([]byte, error)
+}
+
+func newTreeFS() *treeFS {
+	return &treeFS{
+		files: make(map[string]*treeFile),
+		dirs:  map[string][]fs.DirEntry{".": nil},
+	}
+}
+
+// add records f and makes sure every parent directory lists it.
+func (t *treeFS) add(f *treeFile) {
+	t.files[f.name] = f
+	entry := fs.FileInfoToDirEntry(f.info())
+	for name := f.name; name != "."; {
+		parent := path.Dir(name)
+		_, seen := t.dirs[parent]
+		t.dirs[parent] = append(t.dirs[parent], entry)
+		if seen {
+			return
+		}
+		entry = fs.FileInfoToDirEntry(treeDirInfo(parent))
+		name = parent
+	}
+}
+
+// sort orders directory listings by name, as fs.ReadDir promises. Call it
+// once every file has been added.
+func (t *treeFS) sort() {
+	for _, entries := range t.dirs {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+	}
+}
+
+func (t *treeFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if f, ok := t.files[name]; ok {
+		if f.at != nil {
+			return &openTreeFile{f, io.NewSectionReader(f.at, f.offset, f.size)}, nil
+		}
+		data := f.data
+		if f.load != nil {
+			var err error
+			if data, err = f.load(); err != nil {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+			}
+		}
+		return &openTreeFile{f, bytes.NewReader(data)}, nil
+	}
+	if entries, ok := t.dirs[name]; ok {
+		return &openTreeDir{name: name, entries: entries}, nil
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+func (f *treeFile) info() fs.FileInfo {
+	return treeFileInfo{f}
+}
+
+type treeFileInfo struct{ f *treeFile }
+
+func (i treeFileInfo) Name() string       { return path.Base(i.f.name) }
+func (i treeFileInfo) Size() int64        { return i.f.size }
+func (i treeFileInfo) Mode() fs.FileMode  { return i.f.mode }
+func (i treeFileInfo) ModTime() time.Time { return i.f.modTime }
+func (i treeFileInfo) IsDir() bool        { return false }
+func (i treeFileInfo) Sys() interface{}   { return nil }
+
+type treeDirInfo string
+
+func (d treeDirInfo) Name() string       { return path.Base(string(d)) }
+func (d treeDirInfo) Size() int64        { return 0 }
+func (d treeDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d treeDirInfo) ModTime() time.Time { return time.Time{} }
+func (d treeDirInfo) IsDir() bool        { return true }
+func (d treeDirInfo) Sys() interface{}   { return nil }
+
+type openTreeFile struct {
+	f *treeFile
+	contentReader
+}
+
+// contentReader is what bytes.Reader and io.SectionReader have in common.
+type contentReader interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+}
+
+func (o *openTreeFile) Stat() (fs.FileInfo, error) { return o.f.info(), nil }
+func (o *openTreeFile) Close() error               { return nil }
+
+type openTreeDir struct {
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openTreeDir) Stat() (fs.FileInfo, error) { return treeDirInfo(d.name), nil }
+func (d *openTreeDir) Close() error               { return nil }
+
+func (d *openTreeDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *openTreeDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+	d.offset += n
+	return remaining[:n], nil
+}
@@ -0,0 +1,340 @@
+// Package walker analyzes source trees and reports line, comment, function
+// and class counts per language.
+//
+// Analyze walks a directory, an archive or any fs.FS with a pool of
+// workers and returns a Report;
+// the Render functions turn a Report into tables, JSON, templates or SVG.
+// AnalyzeReader and ScanLines expose the per-file analysis for callers
+// that already have the content in hand.
+package walker
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options controls a call to Analyze.
+type Options struct {
+	// Root is the directory to analyze, or a .zip, .jar, .war, .tar,
+	// .tar.gz or .tgz archive. Files inside an archive are reported as
+	// archive.zip!/path/in/archive. Zip archives and plain tarballs are
+	// read in place, but a compressed tarball has to be decompressed into
+	// memory, which takes as much as its contents; files in it over 32 MB
+	// are skipped.
+	Root string
+	// FS, when set, is walked instead of Root, and Root is only used as
+	// the prefix of reported paths.
+	FS fs.FS
+	// Rev, when set, analyzes the tree of this git revision instead of the
+	// files on disk. Root must be inside the repository; only the part of
+	// the tree under Root is analyzed, and files the revision's
+	// .gitattributes mark linguist-vendored, linguist-generated,
+	// linguist-documentation or binary are skipped. The git command must
+	// be installed.
+	Rev string
+	// NestedArchives makes Analyze open archives found during the walk
+	// and analyze their contents, rather than treating them as files.
+	// Each is read into memory first; those over 32 MB are skipped.
+	NestedArchives bool
+	// Exclude lists patterns matched against each file's base name with
+	// filepath.Match, or as a substring of its path. DefaultExcludes is
+	// not applied automatically.
+	Exclude []string
+	// Include, when non-empty, limits analysis to files whose base name
+	// matches one of these patterns.
+	Include []string
+	// Workers is the number of files analyzed concurrently. Zero means 20.
+	Workers int
+	// Registry resolves files to languages. Nil means DefaultRegistry.
+	Registry *Registry
+	// Progress, when set, is called once with done == 0 before any file
+	// is analyzed and again after every file. Calls are serialized.
+	Progress func(done, total int)
+	// OnFile, when set, is called from the worker goroutines as each file
+	// completes. Calls are serialized, and none is made after Analyze
+	// returns.
+	OnFile func(lang string, stats FileStats)
+	// Dedupe counts each distinct file content once: of a group of
+	// identical files only the first path, in sorted order, is kept in
+	// the report's languages. Report.DuplicateFiles lists the groups
+	// either way, and OnFile still sees every file.
+	Dedupe bool
+	// Generated decides which files are tagged FileStats.Generated. Nil
+	// means DefaultGeneratedRules.
+	Generated *GeneratedRules
+	// ExcludeGenerated leaves generated files out of the report entirely.
+	ExcludeGenerated bool
+	// Tests decides which files are test code. Nil means DefaultTestRules.
+	Tests *TestRules
+	// Complexity computes cyclomatic complexity, Halstead measures and the
+	// maintainability index for every file and function. Matching each
+	// line's decision points and tokens takes several times as long as
+	// counting lines, so without it those measures are left zero.
+	Complexity bool
+	// Clones, when set, looks for duplicated code across the tree and
+	// fills in Report.Clones and each language's Duplication. Workers
+	// tokenize each file as they read it and keep only hashes of its
+	// tokens, not its text. A cancelled analysis skips it.
+	Clones *CloneOptions
+
+	// cache lets History reuse counts for blobs it has already analyzed.
+	cache *blobCache
+}
+
+// Report is the result of an analysis.
+type Report struct {
+	GeneratedAt time.Time
+	Root        string
+	Languages   map[string]*LanguageStats
+	// Revision is the commit analyzed when Options.Rev was set.
+	Revision string
+	// QualityGates holds the outcome of CheckRules when the caller ran it.
+	QualityGates *GateResults
+	// Incomplete is set when the analysis was cancelled before every file
+	// had been analyzed. The counts cover only the files that finished.
+	Incomplete bool
+	// Clones is set when Options.Clones asked for duplicate detection.
+	Clones *CloneReport
+	// DuplicateFiles lists groups of files with identical content, most
+	// redundant bytes first. Deduplicated is set when Options.Dedupe
+	// removed all but one file of each group from Languages.
+	DuplicateFiles []DuplicateFiles
+	Deduplicated   bool
+}
+
+const (
+	defaultWorkers = 20
+
+	// drainTimeout bounds how long a cancelled Analyze waits for files
+	// already being read. A read that never returns (a hung network
+	// mount) is abandoned rather than holding up the partial report.
+	drainTimeout = time.Second
+)
+
+// Analyze walks opts.Root and analyzes every file a language is
+// registered for. A hard link or symlink to a file already walked is
+// skipped. An error opening Root as an archive is returned before
+// any work starts.
+//
+// When ctx is cancelled Analyze stops walking, lets in-flight files finish
+// for a short while, and returns what it has in a report marked Incomplete
+// together with ctx.Err().
+func Analyze(ctx context.Context, opts Options) (*Report, error) {
+	registry := opts.Registry
+	if registry == nil {
+		registry = DefaultRegistry()
+	}
+	numWorkers := opts.Workers
+	if numWorkers <= 0 {
+		numWorkers = defaultWorkers
+	}
+
+	src, err := openSource(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer src.close()
+
+	report := &Report{
+		GeneratedAt: time.Now(),
+		Root:        opts.Root,
+		Languages:   make(map[string]*LanguageStats),
+		Revision:    src.revision,
+	}
+	var mu sync.Mutex
+	abandoned := false
+	skipped := false
+	clones := newCloneIndex(opts.Clones)
+	generated := opts.Generated
+	if generated == nil {
+		rules := DefaultGeneratedRules()
+		generated = &rules
+	}
+	tests := opts.Tests
+	if tests == nil {
+		rules := DefaultTestRules()
+		tests = &rules
+	}
+
+	var totalFiles int
+	if opts.Progress != nil {
+		links := make(sameFiles)
+		walkSource(ctx, src, opts.NestedArchives, func(ref fileRef) error {
+			if shouldProcessFile(ref, opts, registry) && !links.seen(ref) {
+				totalFiles++
+			}
+			return nil
+		})
+		opts.Progress(0, totalFiles)
+	}
+
+	fileChan := make(chan fileRef, 1000)
+	var wg sync.WaitGroup
+	done := 0
+
+	worker := func() {
+		defer wg.Done()
+		for ref := range fileChan {
+			// Keep draining after cancellation so the walk never blocks
+			// on a full channel, but stop doing any work.
+			if ctx.Err() != nil {
+				mu.Lock()
+				skipped = true
+				mu.Unlock()
+				continue
+			}
+
+			var fileStats FileStats
+			lang, ok := ref.detect(registry)
+			var tokens *cloneScanner
+			if ok {
+				langConfig, _ := registry.Lookup(lang)
+				langConfig.skipComplexity = !opts.Complexity
+				if clones != nil {
+					tokens = clones.scanner(langConfig)
+				}
+				var cached bool
+				fileStats, cached = opts.cache.get(ref, lang)
+				if !cached {
+					fileStats = analyzeRef(ref, langConfig, registry.AnalyzerFor(lang), generated, tokens)
+					if !opts.Complexity {
+						fileStats.dropComplexity()
+					}
+					opts.cache.put(ref, lang, fileStats)
+				} else if tokens != nil {
+					tokens.readFile(ref)
+				}
+				tests.classify(ref.name, &fileStats)
+				// Generated files are still read to find their markers, but
+				// an excluded one goes no further.
+				if fileStats.Generated && opts.ExcludeGenerated {
+					ok = false
+				}
+			}
+			if ok {
+				if clones != nil {
+					clones.add(ref.display, lang, tokens)
+				}
+
+				mu.Lock()
+				if abandoned {
+					mu.Unlock()
+					return
+				}
+				langStats := report.Languages[lang]
+				if langStats == nil {
+					langStats = &LanguageStats{FileStats: make([]FileStats, 0)}
+					report.Languages[lang] = langStats
+				}
+				langStats.add(fileStats)
+				langStats.FileStats = append(langStats.FileStats, fileStats)
+				// Under the lock so no call starts once Analyze has given
+				// up on the workers and returned.
+				if opts.OnFile != nil {
+					opts.OnFile(lang, fileStats)
+				}
+				mu.Unlock()
+			}
+
+			if opts.Progress != nil {
+				mu.Lock()
+				if !abandoned {
+					done++
+					opts.Progress(done, totalFiles)
+				}
+				mu.Unlock()
+			}
+		}
+	}
+
+	wg.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go worker()
+	}
+
+	walkErr := make(chan error, 1)
+	go func() {
+		links := make(sameFiles)
+		walkErr <- walkSource(ctx, src, opts.NestedArchives, func(ref fileRef) error {
+			if !shouldProcessFile(ref, opts, registry) || links.seen(ref) {
+				return nil
+			}
+			select {
+			case fileChan <- ref:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+		close(fileChan)
+	}()
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	complete := false
+	select {
+	case <-finished:
+		complete = true
+	case <-ctx.Done():
+		select {
+		case <-finished:
+			complete = true
+		case <-time.After(drainTimeout):
+		}
+	}
+
+	if !complete {
+		mu.Lock()
+		abandoned = true
+		report.Incomplete = true
+		report.groupDuplicateFiles(opts.Dedupe)
+		report.summarize()
+		mu.Unlock()
+		return report, ctx.Err()
+	}
+
+	// Every worker has exited, so the walk has too.
+	err = <-walkErr
+	report.groupDuplicateFiles(opts.Dedupe)
+	report.summarize()
+	if clones != nil && !skipped && ctx.Err() == nil {
+		report.findClones(clones)
+	}
+	if skipped || (err != nil && ctx.Err() != nil) {
+		report.Incomplete = true
+		return report, ctx.Err()
+	}
+	return report, err
+}
+
+func shouldProcessFile(ref fileRef, opts Options, registry *Registry) bool {
+	path := ref.display
+	for _, pattern := range opts.Exclude {
+		if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+			return false
+		}
+		if strings.Contains(path, pattern) {
+			return false
+		}
+	}
+
+	if len(opts.Include) > 0 {
+		for _, pattern := range opts.Include {
+			if matched, _ := filepath.Match(pattern, filepath.Base(path)); matched {
+				return true
+			}
+		}
+		return false
+	}
+
+	_, ok := ref.detect(registry)
+	return ok
+}
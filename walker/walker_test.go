@@ -0,0 +1,81 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// benchmarkTree is a few hundred Go, Python and JavaScript files, each a
+// couple of hundred lines of functions with branches in them.
+func benchmarkTree() fstest.MapFS {
+	goFunc := "func f%d(a, b int) int {\n\tif a > b && b > 0 {\n\t\treturn a\n\t}\n\tfor i := 0; i < b; i++ {\n\t\ta += i // step\n\t}\n\treturn a + len(\"{if}\")\n}\n\n"
+	pyFunc := "def f%d(a, b):\n    if a > b and b > 0:\n        return a\n    for i in range(b):\n        a += i  # step\n    return a\n\n"
+	jsFunc := "function f%d(a, b) {\n  if (a > b || b === 0) {\n    return a;\n  }\n  return a ? b : 'if';\n}\n\n"
+
+	fsys := fstest.MapFS{}
+	for i := 0; i < 100; i++ {
+		for ext, fn := range map[string]string{".go": goFunc, ".py": pyFunc, ".js": jsFunc} {
+			var b strings.Builder
+			if ext == ".go" {
+				b.WriteString("package bench\n\n")
+			}
+			for j := 0; j < 25; j++ {
+				fmt.Fprintf(&b, fn, j)
+			}
+			fsys[fmt.Sprintf("pkg%d/file%d%s", i%10, i, ext)] = &fstest.MapFile{Data: []byte(b.String())}
+		}
+	}
+	return fsys
+}
+
+// BenchmarkAnalyze compares a run that only counts lines, functions and
+// nesting with one that also works out complexity and Halstead measures.
+func BenchmarkAnalyze(b *testing.B) {
+	fsys := benchmarkTree()
+	for _, complexity := range []bool{false, true} {
+		name := "counts"
+		if complexity {
+			name = "complexity"
+		}
+		b.Run(name, func(b *testing.B) {
+			opts := Options{Root: "src", FS: fsys, Workers: 1, Complexity: complexity}
+			for i := 0; i < b.N; i++ {
+				if _, err := Analyze(context.Background(), opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeComplexityOption(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.py": &fstest.MapFile{Data: []byte("def f(a):\n    if a:\n        return 1\n    return 2\n")},
+		"b.go": &fstest.MapFile{Data: []byte("package b\n\nfunc g(a int) int {\n\tif a > 0 {\n\t\treturn 1\n\t}\n\treturn 2\n}\n")},
+	}
+	for _, complexity := range []bool{false, true} {
+		report, err := Analyze(context.Background(), Options{Root: "src", FS: fsys, Complexity: complexity})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for lang, stats := range report.Languages {
+			file := stats.FileStats[0]
+			if len(file.FunctionStats) != 1 {
+				t.Fatalf("%s: found %d functions, want 1", lang, len(file.FunctionStats))
+			}
+			want := 0
+			if complexity {
+				want = 2
+			}
+			if got := file.FunctionStats[0].Complexity; got != want {
+				t.Errorf("%s with Complexity %v: function complexity %d, want %d", lang, complexity, got, want)
+			}
+			if measured := file.Halstead.Volume > 0 && file.Maintainability != 0; measured != complexity {
+				t.Errorf("%s with Complexity %v: Halstead volume %v, maintainability %v", lang, complexity, file.Halstead.Volume, file.Maintainability)
+			}
+		}
+	}
+}